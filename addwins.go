@@ -0,0 +1,160 @@
+package bandit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type (
+	// VersionVector records, for each replica, how many of its additions
+	// have been observed.
+	VersionVector map[string]uint64
+
+	// AddWinsSet is a state-based CRDT over an IntervalSet. Each replica
+	// tags its additions, and a removal only discards the additions it has
+	// observed, so an addition concurrent with a removal survives a merge.
+	AddWinsSet struct {
+		replica string
+		version VersionVector
+		entries *IntervalMap
+	}
+
+	dot struct {
+		replica string
+		counter uint64
+	}
+)
+
+func (v VersionVector) contains(d dot) bool {
+	return v[d.replica] >= d.counter
+}
+
+func (v VersionVector) merge(other VersionVector) VersionVector {
+	out := make(VersionVector, len(v))
+	for r, c := range v {
+		out[r] = c
+	}
+	for r, c := range other {
+		if c > out[r] {
+			out[r] = c
+		}
+	}
+	return out
+}
+
+func (v VersionVector) String() string {
+	s := make([]string, 0, len(v))
+	for r, c := range v {
+		s = append(s, fmt.Sprintf("%s:%d", r, c))
+	}
+	sort.Strings(s)
+	return "{" + strings.Join(s, ", ") + "}"
+}
+
+func NewAddWinsSet(replica string) *AddWinsSet {
+	return &AddWinsSet{
+		replica: replica,
+		version: make(VersionVector),
+		entries: NewIntervalMap(),
+	}
+}
+
+func (z *AddWinsSet) Replica() string {
+	return z.replica
+}
+
+// Version returns a copy of the version vector of z.
+func (z *AddWinsSet) Version() VersionVector {
+	return z.version.merge(nil)
+}
+
+func (z *AddWinsSet) String() string {
+	return fmt.Sprintf("%s %s", z.version, z.Value())
+}
+
+// Copy makes z a copy of x, keeping its own replica ID. Copying nil
+// leaves z empty, with nothing observed.
+func (z *AddWinsSet) Copy(x *AddWinsSet) *AddWinsSet {
+	if z == x {
+		return z
+	}
+	if x == nil {
+		z.version = make(VersionVector)
+		z.entries = NewIntervalMap()
+		return z
+	}
+	z.version = x.version.merge(nil)
+	z.entries = CopyMap(x.entries)
+	return z
+}
+
+// Add marks ival as present, tagged with a fresh addition from this replica.
+func (z *AddWinsSet) Add(ival ...Interval) *AddWinsSet {
+	set := NewIntervalSet(ival...)
+	if set.IsEmpty() {
+		return z
+	}
+	z.version[z.replica] += 1
+	z.entries.AddSet(z.entries, dot{z.replica, z.version[z.replica]}, set)
+	return z
+}
+
+// Remove discards ival from every addition this replica has observed.
+func (z *AddWinsSet) Remove(ival ...Interval) *AddWinsSet {
+	mask := NewIntervalSet(ival...)
+	z.entries.Mask(z.entries, mask.Complement(mask))
+	return z
+}
+
+// Merge sets z to the join of x and y. Merge is commutative, associative
+// and idempotent, so replicas converge regardless of the order in which
+// they exchange state.
+func (z *AddWinsSet) Merge(x, y *AddWinsSet) *AddWinsSet {
+	switch {
+	case x == nil:
+		return z.Copy(y)
+	case y == nil:
+		return z.Copy(x)
+	}
+	entries := NewMap(x.entries.Caps())
+	for k, xidx := range x.entries.m {
+		xset := &x.entries.sets[xidx].IntervalSet
+		yidx, ok := y.entries.m[k]
+		switch {
+		case ok:
+			yset := &y.entries.sets[yidx].IntervalSet
+			entries.AddSet(entries, k, NewIntervalSet().Intersection(xset, yset))
+		case !y.version.contains(k.(dot)):
+			entries.AddSet(entries, k, xset)
+		}
+	}
+	for k, yidx := range y.entries.m {
+		if _, ok := x.entries.m[k]; ok || x.version.contains(k.(dot)) {
+			continue
+		}
+		entries.AddSet(entries, k, &y.entries.sets[yidx].IntervalSet)
+	}
+	z.version = x.version.merge(y.version)
+	z.entries = entries
+	return z
+}
+
+// Value returns the set of points currently present.
+func (z *AddWinsSet) Value() *IntervalSet {
+	return z.entries.AllIntervals()
+}
+
+// Equals reports whether z and x hold the same replicated state, regardless
+// of replica ID.
+func (z *AddWinsSet) Equals(x *AddWinsSet) bool {
+	if len(z.version) != len(x.version) {
+		return false
+	}
+	for r, c := range z.version {
+		if x.version[r] != c {
+			return false
+		}
+	}
+	return z.entries.Equals(x.entries)
+}
@@ -0,0 +1,118 @@
+package bandit_test
+
+import (
+	"fmt"
+	"math/rand"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/iancmcc/bandit"
+)
+
+func randomReplicas(r *rand.Rand, n, ops int) []*AddWinsSet {
+	replicas := make([]*AddWinsSet, n)
+	for i := range replicas {
+		replicas[i] = NewAddWinsSet(fmt.Sprintf("r%d", i))
+	}
+	for i := 0; i < ops; i++ {
+		a := replicas[r.Intn(n)]
+		switch r.Intn(3) {
+		case 0:
+			a.Add(randomInterval(r, 1000))
+		case 1:
+			a.Remove(randomInterval(r, 1000))
+		case 2:
+			b := replicas[r.Intn(n)]
+			a.Merge(a, b)
+		}
+	}
+	return replicas
+}
+
+var _ = Describe("AddWinsSet", func() {
+
+	It("should let a concurrent add win over a remove", func() {
+		a, b := NewAddWinsSet("a"), NewAddWinsSet("b")
+		a.Add(Closed(0, 10))
+		b.Merge(b, a)
+		b.Remove(Closed(0, 10))
+		a.Add(Closed(5, 15))
+		a.Merge(a, b)
+		b.Merge(b, a)
+		Ω(a.Value().Equals(NewIntervalSet(Closed(5, 15)))).Should(BeTrue(), "%s", a)
+		Ω(b.Equals(a)).Should(BeTrue())
+	})
+
+	It("should only remove what has been observed", func() {
+		a, b := NewAddWinsSet("a"), NewAddWinsSet("b")
+		a.Add(Closed(0, 10))
+		b.Add(Closed(20, 30))
+		b.Merge(b, a)
+		b.Remove(Closed(5, 25))
+		a.Merge(a, b)
+		Ω(a.Value().String()).Should(Equal("[0, 5), (25, 30]"))
+	})
+
+	It("should merge commutatively", func() {
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 50; i++ {
+			replicas := randomReplicas(r, 3, 40)
+			x, y := replicas[0], replicas[1]
+			xy := NewAddWinsSet("xy").Merge(x, y)
+			yx := NewAddWinsSet("yx").Merge(y, x)
+			Ω(xy.Equals(yx)).Should(BeTrue(), "%s != %s", xy, yx)
+		}
+	})
+
+	It("should merge associatively", func() {
+		r := rand.New(rand.NewSource(2))
+		for i := 0; i < 50; i++ {
+			replicas := randomReplicas(r, 3, 40)
+			x, y, w := replicas[0], replicas[1], replicas[2]
+			left := NewAddWinsSet("l")
+			left.Merge(left.Merge(x, y), w)
+			right := NewAddWinsSet("r")
+			right.Merge(x, right.Merge(y, w))
+			Ω(left.Equals(right)).Should(BeTrue(), "%s != %s", left, right)
+		}
+	})
+
+	It("should merge idempotently", func() {
+		r := rand.New(rand.NewSource(3))
+		for i := 0; i < 50; i++ {
+			x := randomReplicas(r, 3, 40)[0]
+			xx := NewAddWinsSet("xx").Merge(x, x)
+			Ω(xx.Equals(x)).Should(BeTrue(), "%s != %s", xx, x)
+		}
+	})
+
+	It("should merge with nil", func() {
+		x := NewAddWinsSet("x").Add(Closed(1, 5))
+		Ω(NewAddWinsSet("z").Merge(x, nil).Equals(x)).Should(BeTrue())
+		Ω(NewAddWinsSet("z").Merge(nil, x).Equals(x)).Should(BeTrue())
+		z := NewAddWinsSet("z").Merge(x, x)
+		Ω(z.Merge(nil, nil).Value().IsEmpty()).Should(BeTrue())
+		Ω(z.Version()).Should(BeEmpty())
+		Ω(z.Add(Point(3)).Value().Equals(NewIntervalSet(Point(3)))).Should(BeTrue())
+	})
+
+	It("should converge under arbitrary merge orders", func() {
+		r := rand.New(rand.NewSource(4))
+		for i := 0; i < 50; i++ {
+			replicas := randomReplicas(r, 4, 60)
+			var final *AddWinsSet
+			for j := 0; j < 4; j++ {
+				acc := NewAddWinsSet("acc")
+				for _, k := range r.Perm(len(replicas)) {
+					acc.Merge(acc, replicas[k])
+				}
+				if final != nil {
+					Ω(acc.Equals(final)).Should(BeTrue(), "%s != %s", acc, final)
+					Ω(acc.Value().Equals(final.Value())).Should(BeTrue())
+				}
+				final = acc
+			}
+		}
+	})
+})
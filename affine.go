@@ -0,0 +1,191 @@
+package bandit
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// OverflowPolicy decides what happens to values mapped outside the uint64
+// domain.
+type OverflowPolicy uint8
+
+const (
+	// ClipOverflow discards whatever falls outside the domain.
+	ClipOverflow OverflowPolicy = iota
+	// ErrorOnOverflow fails with ErrOverflow instead.
+	ErrorOnOverflow
+)
+
+var ErrOverflow = errors.New("value outside the uint64 domain")
+
+// affineAt returns a*v + extra + b, along with -1, 0 or 1 as the result
+// falls below, within or above the domain.
+func affineAt(a, v uint64, b int64, extra uint64) (uint64, int) {
+	hi, lo := bits.Mul64(a, v)
+	var c uint64
+	lo, c = bits.Add64(lo, extra, 0)
+	hi += c
+	if b >= 0 {
+		lo, c = bits.Add64(lo, uint64(b), 0)
+		hi += c
+	} else {
+		// -b wraps for math.MinInt64, but uint64 still gets its magnitude
+		lo, c = bits.Sub64(lo, uint64(-b), 0)
+		if hi < c {
+			return 0, -1
+		}
+		hi -= c
+	}
+	if hi != 0 {
+		return math.MaxUint64, 1
+	}
+	return lo, 0
+}
+
+// affineFrom returns (y-b)/a for a > 0, rounded down, whether it was exact,
+// and -1, 0 or 1 as the quotient falls below, within or above the domain.
+func affineFrom(y uint64, b int64, a uint64) (uint64, bool, int) {
+	var hi, lo uint64
+	switch {
+	case b >= 0 && y < uint64(b):
+		return 0, false, -1
+	case b >= 0:
+		lo = y - uint64(b)
+	default:
+		lo, hi = bits.Add64(y, uint64(-b), 0)
+	}
+	if hi >= a {
+		return math.MaxUint64, false, 1
+	}
+	q, r := bits.Div64(hi, lo, a)
+	return q, r == 0, 0
+}
+
+// affineImage maps an interval through v ↦ a*v + b. If discrete, each value
+// stands for the unit cell [v, v+1), so the image covers whole cells.
+func affineImage(a uint64, b int64, discrete bool, policy OverflowPolicy, lb BoundType, lower, upper uint64, ub BoundType) (Interval, error) {
+	if a == 0 {
+		// Everything lands on b
+		v, pos := affineAt(0, 0, b, 0)
+		switch {
+		case pos == 0:
+			return Point(v), nil
+		case policy == ErrorOnOverflow:
+			return Empty(), ErrOverflow
+		}
+		return Empty(), nil
+	}
+	if lb != UnboundBound {
+		v, pos := affineAt(a, lower, b, 0)
+		if pos != 0 && policy == ErrorOnOverflow {
+			return Empty(), ErrOverflow
+		}
+		switch pos {
+		case -1:
+			lb = ClosedBound
+		case 1:
+			return Empty(), nil
+		}
+		lower = v
+	}
+	if ub != UnboundBound {
+		var extra uint64
+		if discrete {
+			extra = a - 1
+		}
+		v, pos := affineAt(a, upper, b, extra)
+		if pos != 0 && policy == ErrorOnOverflow {
+			return Empty(), ErrOverflow
+		}
+		switch pos {
+		case -1:
+			return Empty(), nil
+		case 1:
+			ub = ClosedBound
+		}
+		upper = v
+	}
+	return NewInterval(lb, lower, upper, ub), nil
+}
+
+// affinePreimage returns the values v for which a*v + b falls within an
+// interval. Bounds that don't land on a whole v are rounded inward.
+func affinePreimage(a uint64, b int64, lb BoundType, lower, upper uint64, ub BoundType) Interval {
+	if lb != UnboundBound {
+		q, exact, pos := affineFrom(lower, b, a)
+		switch {
+		case pos < 0:
+			lb, q = ClosedBound, 0
+		case pos > 0:
+			return Empty()
+		case !exact:
+			lb, q = ClosedBound, q+1
+		}
+		lower = q
+	}
+	if ub != UnboundBound {
+		q, exact, pos := affineFrom(upper, b, a)
+		switch {
+		case pos < 0:
+			return Empty()
+		case pos > 0:
+			ub = ClosedBound
+		case !exact:
+			ub = ClosedBound
+		}
+		upper = q
+	}
+	return NewInterval(lb, lower, upper, ub)
+}
+
+// Affine sets z to the image of x under v ↦ a*v + b, and returns z. Bound
+// types carry over, except where policy clips at the edge of the domain. In
+// discrete mode each value of x stands for the unit cell [v, v+1), so
+// scaling [1, 3] by 10 gives [10, 39]. With ErrorOnOverflow, z is left
+// alone if any value would leave the domain.
+func (z *IntervalSet) Affine(x *IntervalSet, a uint64, b int64, policy OverflowPolicy) (*IntervalSet, error) {
+	var discrete bool
+	if x != nil {
+		discrete = x.discrete
+	}
+	return z.tryRemap(x, func(lb BoundType, lower, upper uint64, ub BoundType) (Interval, error) {
+		return affineImage(a, b, discrete, policy, lb, lower, upper, ub)
+	})
+}
+
+// Shift sets z to x with every value moved by delta, and returns z.
+func (z *IntervalSet) Shift(x *IntervalSet, delta int64, policy OverflowPolicy) (*IntervalSet, error) {
+	return z.Affine(x, 1, delta, policy)
+}
+
+// Scale sets z to x with every value multiplied by factor, and returns z.
+func (z *IntervalSet) Scale(x *IntervalSet, factor uint64, policy OverflowPolicy) (*IntervalSet, error) {
+	return z.Affine(x, factor, 0, policy)
+}
+
+// Preimage sets z to the values v for which a*v + b is in x, and returns z.
+// It undoes Affine. Where a bound of x doesn't correspond to a whole v, it
+// is rounded inward to the nearest one that does.
+func (z *IntervalSet) Preimage(x *IntervalSet, a uint64, b int64) *IntervalSet {
+	if a == 0 {
+		// Every value maps to b, so it's all or nothing
+		v, pos := affineAt(0, 0, b, 0)
+		member := false
+		switch {
+		case x == nil:
+		case pos < 0:
+			// b lies below the domain, where x holds what it holds at -∞
+			member = x.ul
+		default:
+			c, ok := x.Ceiling(v)
+			member = ok && c == v
+		}
+		z.Clear()
+		z.ul = member
+		return z.normalize()
+	}
+	return z.remap(x, func(lb BoundType, lower, upper uint64, ub BoundType) Interval {
+		return affinePreimage(a, b, lb, lower, upper, ub)
+	})
+}
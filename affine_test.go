@@ -0,0 +1,69 @@
+package bandit_test
+
+import (
+	"math"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	. "github.com/iancmcc/bandit"
+)
+
+var _ = Describe("Affine maps", func() {
+
+	DescribeTable("mapping continuous sets", func(x *IntervalSet, a, b int, expected *IntervalSet) {
+		actual, err := NewIntervalSet().Affine(x, uint64(a), int64(b), ClipOverflow)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(actual.Equals(expected)).Should(BeTrue(), "%s != %s", actual, expected)
+	},
+		Entry("identity", NewIntervalSet(Open(1, 5), Point(9)), 1, 0, NewIntervalSet(Open(1, 5), Point(9))),
+		Entry("scale", NewIntervalSet(Open(1, 5), Point(9)), 1000, 0, NewIntervalSet(Open(1000, 5000), Point(9000))),
+		Entry("scale and offset", NewIntervalSet(LeftOpen(1, 5)), 2, 3, NewIntervalSet(LeftOpen(5, 13))),
+		Entry("unbounded ends", NewIntervalSet(Below(5), Above(10)), 2, -4, NewIntervalSet(Below(6), Above(16))),
+		Entry("a hole", NewIntervalSet(Below(5), Above(5)), 3, 0, NewIntervalSet(Below(15), Above(15))),
+		Entry("collapse", NewIntervalSet(Open(1, 5), Above(10)), 0, 7, NewIntervalSet(Point(7))),
+		Entry("clip below", NewIntervalSet(Open(2, 8), Point(1)), 1, -5, NewIntervalSet(RightOpen(0, 3))),
+		Entry("clip above", NewIntervalSet(Open(1<<62, 1<<63)), 3, 0, NewIntervalSet(LeftOpen(3<<62, math.MaxUint64))),
+	)
+
+	It("should fail on overflow if asked to", func() {
+		x := NewIntervalSet(Closed(10, 20))
+		_, err := NewIntervalSet().Shift(x, -11, ErrorOnOverflow)
+		Ω(err).Should(Equal(ErrOverflow))
+		_, err = NewIntervalSet().Scale(x, math.MaxUint64/15, ErrorOnOverflow)
+		Ω(err).Should(Equal(ErrOverflow))
+		z := NewIntervalSet(Point(1))
+		_, err = z.Shift(NewIntervalSet(Closed(1<<63+1, 1<<63+2)), math.MaxInt64, ErrorOnOverflow)
+		Ω(err).Should(HaveOccurred())
+		Ω(z.Equals(NewIntervalSet(Point(1)))).Should(BeTrue())
+		_, err = z.Shift(x, -10, ErrorOnOverflow)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(z.Equals(NewIntervalSet(Closed(0, 10)))).Should(BeTrue())
+	})
+
+	It("should scale discrete values as whole cells", func() {
+		pages := NewIntervalSet(Closed(2, 3)).SetDiscrete(true)
+		bytes, err := NewIntervalSet().SetDiscrete(true).Scale(pages, 4096, ClipOverflow)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(bytes.Equals(NewIntervalSet(Closed(8192, 16383)).SetDiscrete(true))).Should(BeTrue(), "%s", bytes)
+		back := NewIntervalSet().SetDiscrete(true).Preimage(bytes, 4096, 0)
+		Ω(back.Equals(pages)).Should(BeTrue(), "%s", back)
+	})
+
+	It("should find preimages", func() {
+		x := NewIntervalSet(Open(1000, 5000), Point(9000))
+		Ω(NewIntervalSet().Preimage(x, 1000, 0).Equals(NewIntervalSet(Open(1, 5), Point(9)))).Should(BeTrue())
+		// Bounds between multiples round inward
+		y := NewIntervalSet().Preimage(NewIntervalSet(Open(4, 11)), 3, 0)
+		Ω(y.Equals(NewIntervalSet(Closed(2, 3)))).Should(BeTrue(), "%s", y)
+		y = NewIntervalSet().Preimage(NewIntervalSet(Open(4, 5)), 3, 0)
+		Ω(y.IsEmpty()).Should(BeTrue(), "%s", y)
+		y = NewIntervalSet().Preimage(NewIntervalSet(AtOrAbove(5)), 1, 10)
+		Ω(y.Equals(NewIntervalSet(AtOrAbove(0)))).Should(BeTrue(), "%s", y)
+		y = NewIntervalSet().Preimage(NewIntervalSet(Closed(5, 7)), 0, 6)
+		Ω(y.IsUnbounded()).Should(BeTrue())
+		y = NewIntervalSet().Preimage(NewIntervalSet(Closed(5, 7)), 0, 8)
+		Ω(y.IsEmpty()).Should(BeTrue())
+	})
+})
@@ -0,0 +1,168 @@
+package bandit
+
+import "strings"
+
+// Relation is a set of Allen's interval relations. Each of the thirteen
+// basic relations is a single bit, so they combine with | into predicates
+// like Before|Meets.
+type Relation uint16
+
+const (
+	Before Relation = 1 << iota
+	Meets
+	Overlaps
+	Starts
+	During
+	Finishes
+	Equals
+	FinishedBy
+	Contains
+	StartedBy
+	OverlappedBy
+	MetBy
+	After
+
+	// AllRelations holds every basic relation.
+	AllRelations Relation = 1<<iota - 1
+)
+
+var relationNames = [...]string{
+	"before", "meets", "overlaps", "starts", "during", "finishes", "equals",
+	"finished-by", "contains", "started-by", "overlapped-by", "met-by", "after",
+}
+
+// endpoint orders interval bounds on the extended line. Open bounds sit an
+// infinitesimal step inside the value they name, so (5 comes after [5 and
+// 5) before 5].
+type endpoint struct {
+	inf int // -1 or 1 for an unbounded end, else 0
+	v   uint64
+	eps int
+}
+
+func lowerEndpoint(bt BoundType, v uint64) endpoint {
+	switch bt {
+	case UnboundBound:
+		return endpoint{inf: -1}
+	case OpenBound:
+		return endpoint{v: v, eps: 1}
+	}
+	return endpoint{v: v}
+}
+
+func upperEndpoint(bt BoundType, v uint64) endpoint {
+	switch bt {
+	case UnboundBound:
+		return endpoint{inf: 1}
+	case OpenBound:
+		return endpoint{v: v, eps: -1}
+	}
+	return endpoint{v: v}
+}
+
+func (p endpoint) cmp(q endpoint) int {
+	switch {
+	case p.inf != q.inf:
+		return p.inf - q.inf
+	case p.inf != 0, p.v == q.v:
+		return p.eps - q.eps
+	case p.v < q.v:
+		return -1
+	}
+	return 1
+}
+
+// touches reports whether an upper endpoint p falling short of a lower
+// endpoint q leaves nothing between them.
+func (p endpoint) touches(q endpoint) bool {
+	return p.inf == 0 && q.inf == 0 && p.v == q.v && (p.eps == 0 || q.eps == 0)
+}
+
+// Relate returns the basic relation ival stands in to other, or 0 if either
+// is empty. Intervals that share no value but leave none between them, like
+// [1, 5) and [5, 8], meet; sharing a single value, as [1, 5] and [5, 8] do,
+// is an overlap.
+func (ival Interval) Relate(other Interval) Relation {
+	if ival.IsEmpty() || other.IsEmpty() {
+		return 0
+	}
+	alb, alower, aupper, aub := ival.Bounds()
+	blb, blower, bupper, bub := other.Bounds()
+	al, au := lowerEndpoint(alb, alower), upperEndpoint(aub, aupper)
+	bl, bu := lowerEndpoint(blb, blower), upperEndpoint(bub, bupper)
+	if au.cmp(bl) < 0 {
+		if au.touches(bl) {
+			return Meets
+		}
+		return Before
+	}
+	if bu.cmp(al) < 0 {
+		if bu.touches(al) {
+			return MetBy
+		}
+		return After
+	}
+	l, u := al.cmp(bl), au.cmp(bu)
+	switch {
+	case l == 0 && u == 0:
+		return Equals
+	case l == 0 && u < 0:
+		return Starts
+	case l == 0:
+		return StartedBy
+	case u == 0 && l > 0:
+		return Finishes
+	case u == 0:
+		return FinishedBy
+	case l > 0 && u < 0:
+		return During
+	case l < 0 && u > 0:
+		return Contains
+	case l < 0:
+		return Overlaps
+	}
+	return OverlappedBy
+}
+
+// Holds reports whether a stands in any of the relations in r to b.
+func (r Relation) Holds(a, b Interval) bool {
+	return a.Relate(b)&r != 0
+}
+
+// Inverse returns the relations b stands in to a wherever a stands in r to
+// b.
+func (r Relation) Inverse() Relation {
+	var inv Relation
+	for i := uint(0); i < uint(len(relationNames)); i++ {
+		if r&(1<<i) != 0 {
+			inv |= 1 << (uint(len(relationNames)) - 1 - i)
+		}
+	}
+	return inv
+}
+
+func (r Relation) String() string {
+	var names []string
+	for i, name := range relationNames {
+		if r&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// Select returns the intervals of z that stand in any of the relations in r
+// to ival, in order.
+func (z *IntervalSet) Select(r Relation, ival Interval) []Interval {
+	var ivals []Interval
+	z.eachInterval(func(lb BoundType, lower, upper uint64, ub BoundType) bool {
+		if a := NewInterval(lb, lower, upper, ub); r.Holds(a, ival) {
+			ivals = append(ivals, a)
+		}
+		return true
+	})
+	return ivals
+}
@@ -0,0 +1,60 @@
+package bandit_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	. "github.com/iancmcc/bandit"
+)
+
+var _ = Describe("Interval relations", func() {
+
+	DescribeTable("relating intervals", func(a, b Interval, expected Relation) {
+		Ω(a.Relate(b)).Should(Equal(expected), "%s %s %s", a, expected, b)
+		Ω(b.Relate(a)).Should(Equal(expected.Inverse()), "%s %s %s", b, expected.Inverse(), a)
+	},
+		Entry("before", Closed(1, 3), Closed(5, 8), Before),
+		Entry("before across an open point", RightOpen(1, 5), LeftOpen(5, 8), Before),
+		Entry("meets closed to open", Closed(1, 5), LeftOpen(5, 8), Meets),
+		Entry("meets open to closed", RightOpen(1, 5), Closed(5, 8), Meets),
+		Entry("overlaps at a point", Closed(1, 5), Closed(5, 8), Overlaps),
+		Entry("overlaps", Closed(1, 6), Closed(5, 8), Overlaps),
+		Entry("starts", Closed(1, 3), Closed(1, 8), Starts),
+		Entry("starts unbounded", Below(3), Below(8), Starts),
+		Entry("during", Open(1, 8), Closed(1, 8), During),
+		Entry("during unbounded", Closed(1, 8), Unbounded(), During),
+		Entry("finishes", LeftOpen(3, 8), Closed(1, 8), Finishes),
+		Entry("finishes unbounded", Above(3), Above(1), Finishes),
+		Entry("equals", Open(1, 8), Open(1, 8), Equals),
+		Entry("equals unbounded", Unbounded(), Unbounded(), Equals),
+		Entry("point during", Point(3), Open(1, 8), During),
+		Entry("point starts", Point(1), Closed(1, 8), Starts),
+		Entry("point meets", Point(1), LeftOpen(1, 8), Meets),
+	)
+
+	It("should relate nothing to an empty interval", func() {
+		Ω(Empty().Relate(Closed(1, 2))).Should(BeZero())
+		Ω(Closed(1, 2).Relate(Empty())).Should(BeZero())
+		Ω(AllRelations.Holds(Empty(), Empty())).Should(BeFalse())
+	})
+
+	It("should compose relations", func() {
+		precedes := Before | Meets
+		Ω(precedes.Holds(RightOpen(1, 5), Closed(5, 8))).Should(BeTrue())
+		Ω(precedes.Holds(Closed(1, 5), Closed(5, 8))).Should(BeFalse())
+		Ω(precedes.Inverse()).Should(Equal(After | MetBy))
+		Ω(AllRelations.Inverse()).Should(Equal(AllRelations))
+		Ω(precedes.String()).Should(Equal("before|meets"))
+	})
+
+	It("should select intervals of a set", func() {
+		s := NewIntervalSet(Closed(1, 3), Closed(5, 6), Open(8, 10), Closed(12, 20))
+		Ω(s.Select(During, Closed(4, 10))).Should(ConsistOf(
+			WithTransform(Interval.String, Equal("[5, 6]")),
+			WithTransform(Interval.String, Equal("(8, 10)")),
+		))
+		Ω(s.Select(Before|Overlaps, Closed(5, 15))).Should(HaveLen(1))
+		Ω(s.Select(Before|Overlaps, Closed(5, 15))[0].Equals(Closed(1, 3))).Should(BeTrue())
+	})
+})
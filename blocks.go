@@ -0,0 +1,74 @@
+package bandit
+
+import "math/bits"
+
+// MaxBlocksPerInterval bounds the number of aligned blocks AlignedBlocks
+// needs for any one run of consecutive uint64 values: at most one block per
+// level below 63 on either side, as [1, MaxUint64-1] needs.
+const MaxBlocksPerInterval = 2 * 63
+
+// Interval returns the closed interval of values within r, which is empty
+// if r's Level is above 64.
+func (r PrefixRange) Interval() Interval {
+	if !r.valid() {
+		return Empty()
+	}
+	lower := MaskAbove(r.Prefix, r.Level)
+	return Closed(lower, lower|^prefixMasks[r.Level])
+}
+
+// AlignedBlocks returns the fewest aligned prefix ranges that together hold
+// exactly the uint64 values of z, in ascending order. Intervals that leave
+// no uint64 value between them share blocks, as in discrete mode.
+func (z *IntervalSet) AlignedBlocks() []PrefixRange {
+	var (
+		blocks []PrefixRange
+		lo, hi uint64
+		run    bool
+	)
+	z.eachInterval(func(lb BoundType, lower, upper uint64, ub BoundType) bool {
+		l, h, ok := integerRange(lb, lower, upper, ub)
+		switch {
+		case !ok:
+		case run && l == hi+1:
+			hi = h
+		default:
+			if run {
+				blocks = appendBlocks(blocks, lo, hi)
+			}
+			lo, hi, run = l, h, true
+		}
+		return true
+	})
+	if run {
+		blocks = appendBlocks(blocks, lo, hi)
+	}
+	return blocks
+}
+
+// appendBlocks appends the fewest aligned blocks covering [lo, hi], taking
+// the largest block that starts at lo each time.
+func appendBlocks(blocks []PrefixRange, lo, hi uint64) []PrefixRange {
+	for {
+		level := uint(bits.TrailingZeros64(lo))
+		for level > 0 && lo|^prefixMasks[level] > hi {
+			level--
+		}
+		blocks = append(blocks, PrefixRange{lo, level})
+		end := lo | ^prefixMasks[level]
+		if end == hi {
+			return blocks
+		}
+		lo = end + 1
+	}
+}
+
+// FromBlocks returns the discrete set holding every value within blocks.
+func FromBlocks(blocks ...PrefixRange) *IntervalSet {
+	z := NewIntervalSetWithCapacity(uint(len(blocks)))
+	for _, r := range blocks {
+		ival := r.Interval()
+		z.mergeRoot(&z.Tree, &ival.Tree, z.root, ival.root, z.ul, ival.ul, or)
+	}
+	return z.SetDiscrete(true)
+}
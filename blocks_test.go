@@ -0,0 +1,61 @@
+package bandit_test
+
+import (
+	"math"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	. "github.com/iancmcc/bandit"
+)
+
+var _ = Describe("Aligned blocks", func() {
+
+	DescribeTable("decomposing sets", func(x *IntervalSet, expected []PrefixRange) {
+		blocks := x.AlignedBlocks()
+		Ω(blocks).Should(HaveLen(len(expected)))
+		if len(expected) > 0 {
+			Ω(blocks).Should(Equal(expected))
+		}
+		Ω(FromBlocks(blocks...).Equals(NewIntervalSet().Copy(x).SetDiscrete(true))).Should(BeTrue())
+	},
+		Entry("single value", NewIntervalSet(Point(5)), []PrefixRange{{5, 0}}),
+		Entry("aligned block", NewIntervalSet(Closed(8, 15)), []PrefixRange{{8, 3}}),
+		Entry("unaligned run", NewIntervalSet(Closed(3, 12)), []PrefixRange{{3, 0}, {4, 2}, {8, 2}, {12, 0}}),
+		Entry("open bounds", NewIntervalSet(Open(3, 12)), []PrefixRange{{4, 2}, {8, 2}}),
+		Entry("runs meeting at a value", NewIntervalSet(Closed(0, 3), Closed(4, 7)), []PrefixRange{{0, 3}}),
+		Entry("whole domain", NewIntervalSet(Unbounded()), []PrefixRange{WholeDomain}),
+		Entry("top of the domain", NewIntervalSet(Above(math.MaxUint64-4)), []PrefixRange{{math.MaxUint64 - 3, 2}}),
+		Entry("around a hole", NewIntervalSet(Below(1), Above(1)), append([]PrefixRange{{0, 0}}, blocksAbove(2)...)),
+		Entry("empty", NewIntervalSet(Open(1, 2)), []PrefixRange{}),
+	)
+
+	It("should stay within the bound for any run", func() {
+		blocks := NewIntervalSet(Closed(1, math.MaxUint64-1)).AlignedBlocks()
+		Ω(blocks).Should(HaveLen(MaxBlocksPerInterval))
+	})
+
+	It("should give no values for a level beyond the domain", func() {
+		Ω(PrefixRange{0, 64}.Interval().Equals(Closed(0, math.MaxUint64))).Should(BeTrue())
+		Ω(PrefixRange{0, 65}.Interval().IsEmpty()).Should(BeTrue())
+		Ω(FromBlocks(PrefixRange{8, 200}, PrefixRange{3, 0}).Equals(NewIntervalSet(Point(3)).SetDiscrete(true))).Should(BeTrue())
+	})
+
+	It("should merge overlapping blocks", func() {
+		x := FromBlocks(PrefixRange{0, 4}, PrefixRange{4, 1}, PrefixRange{16, 0})
+		Ω(x.IsDiscrete()).Should(BeTrue())
+		Ω(x.Equals(NewIntervalSet(Closed(0, 16)).SetDiscrete(true))).Should(BeTrue(), "%s", x)
+	})
+})
+
+// blocksAbove returns the blocks covering every value from a power of two
+// up to the top of the domain.
+func blocksAbove(lo uint64) []PrefixRange {
+	var blocks []PrefixRange
+	for level := uint(1); level < 64; level++ {
+		blocks = append(blocks, PrefixRange{lo, level})
+		lo <<= 1
+	}
+	return blocks
+}
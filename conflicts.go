@@ -0,0 +1,61 @@
+package bandit
+
+// Conflict is a pair of keys whose sets share members, and the members
+// they share.
+type Conflict struct {
+	A, B    interface{}
+	Overlap *IntervalSet
+}
+
+type keyPair struct {
+	a, b interface{}
+}
+
+// Conflicts returns every pair of keys of z whose sets overlap, in no
+// particular order. Pairs are found in a single sweep over the segments of
+// z, so only keys that actually meet are ever paired.
+func (z *IntervalMap) Conflicts() []Conflict {
+	var (
+		conflicts []Conflict
+		pairs     = make(map[keyPair]int)
+	)
+	for it := z.Segments(); it.Next(); {
+		ival, keys := it.Value()
+		for i, a := range keys {
+			for _, b := range keys[i+1:] {
+				j, ok := pairs[keyPair{a, b}]
+				if !ok {
+					j, ok = pairs[keyPair{b, a}]
+				}
+				if !ok {
+					j = len(conflicts)
+					pairs[keyPair{a, b}] = j
+					conflicts = append(conflicts, Conflict{a, b, NewIntervalSet().SetDiscrete(z.discrete)})
+				}
+				s := conflicts[j].Overlap
+				s.Add(s, ival)
+			}
+		}
+	}
+	return conflicts
+}
+
+// ConflictsWith returns the keys other than key whose sets would overlap
+// ival if it were added under key, paired with key. Only the keys returned
+// by KeysOverlapping are intersected.
+func (z *IntervalMap) ConflictsWith(key interface{}, ival Interval) []Conflict {
+	var conflicts []Conflict
+	if z.discrete {
+		ival = ival.Normalize()
+	}
+	other := ival.AsIntervalSet()
+	for _, k := range z.KeysOverlapping(ival) {
+		if k == key {
+			continue
+		}
+		overlap := NewIntervalSet().SetDiscrete(z.discrete)
+		overlap.Intersection(&z.sets[z.m[k]].IntervalSet, other)
+		conflicts = append(conflicts, Conflict{key, k, overlap})
+	}
+	return conflicts
+}
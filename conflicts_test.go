@@ -0,0 +1,62 @@
+package bandit_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/iancmcc/bandit"
+)
+
+var _ = Describe("Map conflicts", func() {
+	var m *IntervalMap
+
+	BeforeEach(func() {
+		m = imap("a", Closed(0, 10), Closed(20, 30))
+		m.Add(m, "b", Open(5, 25))
+		m.Add(m, "c", Point(8), Above(100))
+		m.Add(m, "d", Closed(40, 50))
+	})
+
+	overlapOf := func(conflicts []Conflict, a, b interface{}) *IntervalSet {
+		for _, c := range conflicts {
+			if (c.A == a && c.B == b) || (c.A == b && c.B == a) {
+				return c.Overlap
+			}
+		}
+		return nil
+	}
+
+	It("should find every pair of overlapping keys", func() {
+		conflicts := m.Conflicts()
+		Ω(conflicts).Should(HaveLen(3))
+		Ω(overlapOf(conflicts, "a", "b").Equals(NewIntervalSet(LeftOpen(5, 10), RightOpen(20, 25)))).Should(BeTrue())
+		Ω(overlapOf(conflicts, "a", "c").Equals(NewIntervalSet(Point(8)))).Should(BeTrue())
+		Ω(overlapOf(conflicts, "b", "c").Equals(NewIntervalSet(Point(8)))).Should(BeTrue())
+	})
+
+	It("should find no conflicts between disjoint keys", func() {
+		n := imap("a", RightOpen(0, 10))
+		n.Add(n, "b", Closed(10, 20))
+		Ω(n.Conflicts()).Should(BeEmpty())
+	})
+
+	It("should ignore fractional overlaps in discrete mode", func() {
+		n := imap("a", Closed(0, 10))
+		n.Add(n, "b", Open(10, 11), Closed(10, 12))
+		n.SetDiscrete(true)
+		conflicts := n.Conflicts()
+		Ω(conflicts).Should(HaveLen(1))
+		Ω(conflicts[0].Overlap.Equals(NewIntervalSet(Point(10)).SetDiscrete(true))).Should(BeTrue())
+	})
+
+	It("should find conflicts with a prospective interval", func() {
+		conflicts := m.ConflictsWith("b", Closed(25, 45))
+		Ω(conflicts).Should(HaveLen(2))
+		for _, c := range conflicts {
+			Ω(c.A).Should(Equal("b"))
+		}
+		Ω(overlapOf(conflicts, "b", "a").Equals(NewIntervalSet(Closed(25, 30)))).Should(BeTrue())
+		Ω(overlapOf(conflicts, "b", "d").Equals(NewIntervalSet(Closed(40, 45)))).Should(BeTrue())
+		Ω(m.ConflictsWith("e", Open(50, 100))).Should(BeEmpty())
+	})
+})
@@ -0,0 +1,72 @@
+package bandit
+
+// digest returns a hash of z that is equal for any two sets that are
+// Equal.
+func (z *IntervalSet) digest() uint64 {
+	var ul uint64
+	if z.ul {
+		ul = 1
+	}
+	return hashWord(z.hash(z.root), ul)
+}
+
+type coverageGroup struct {
+	idx  uint
+	keys []interface{}
+}
+
+// coverageGroups groups the keys of z by the set they hold, bucketing sets
+// by digest so only sets with the same digest are compared.
+func (z *IntervalMap) coverageGroups() []coverageGroup {
+	var (
+		groups  []coverageGroup
+		buckets = make(map[uint64][]int)
+	)
+	for k, idx := range z.m {
+		set := &z.sets[idx].IntervalSet
+		h := set.digest()
+		found := false
+		for _, g := range buckets[h] {
+			gidx := groups[g].idx
+			if gidx == idx || set.Equals(&z.sets[gidx].IntervalSet) {
+				groups[g].keys = append(groups[g].keys, k)
+				found = true
+				break
+			}
+		}
+		if !found {
+			buckets[h] = append(buckets[h], len(groups))
+			groups = append(groups, coverageGroup{idx, []interface{}{k}})
+		}
+	}
+	return groups
+}
+
+// GroupByCoverage returns the keys of z grouped so that two keys share a
+// group exactly when their sets are Equal, in no particular order. Every
+// key falls in one group.
+func (z *IntervalMap) GroupByCoverage() [][]interface{} {
+	groups := z.coverageGroups()
+	out := make([][]interface{}, len(groups))
+	for i, g := range groups {
+		out[i] = g.keys
+	}
+	return out
+}
+
+// ShareCoverage stores each group of keys GroupByCoverage finds in a
+// single set, freeing the others. A key's set is copied again as soon as
+// it changes, leaving the rest of its group alone. Sets reached through a
+// MapIterator mustn't be changed in place once shared.
+func (z *IntervalMap) ShareCoverage() *IntervalMap {
+	for _, g := range z.coverageGroups() {
+		for _, k := range g.keys {
+			if idx := z.m[k]; idx != g.idx {
+				z.free(idx)
+				z.sets[g.idx].refs += 1
+				z.store(k, g.idx)
+			}
+		}
+	}
+	return z
+}
@@ -0,0 +1,67 @@
+package bandit_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/iancmcc/bandit"
+)
+
+var _ = Describe("Coverage groups", func() {
+	var m *IntervalMap
+
+	BeforeEach(func() {
+		m = imap("a", Closed(0, 10), Point(20))
+		m.Add(m, "b", Point(20), Closed(0, 10))
+		m.Add(m, "c", Closed(0, 10))
+		m.Add(m, "d", RightOpen(0, 5), Closed(5, 10), Point(20))
+		m.Add(m, "e", Above(100))
+	})
+
+	It("should group keys with equal sets", func() {
+		Ω(m.GroupByCoverage()).Should(ConsistOf(
+			ConsistOf("a", "b", "d"),
+			ConsistOf("c"),
+			ConsistOf("e"),
+		))
+		Ω(NewIntervalMap().GroupByCoverage()).Should(BeEmpty())
+	})
+
+	It("should group keys equal in discrete mode", func() {
+		n := imap("a", Open(0, 10))
+		n.Add(n, "b", Closed(1, 9))
+		Ω(n.GroupByCoverage()).Should(HaveLen(2))
+		n.SetDiscrete(true)
+		Ω(n.GroupByCoverage()).Should(ConsistOf(ConsistOf("a", "b")))
+	})
+
+	It("should keep shared sets apart once changed", func() {
+		expected := CopyMap(m)
+		m.ShareCoverage()
+		Ω(m.Equals(expected)).Should(BeTrue())
+
+		m.Add(m, "a", Point(30))
+		expected.Add(expected, "a", Point(30))
+		m.SubtractInterval(m, "b", Point(20))
+		expected.SubtractInterval(expected, "b", Point(20))
+		Ω(m.Equals(expected)).Should(BeTrue())
+		Ω(m.Get("d").Equals(NewIntervalSet(Closed(0, 10), Point(20)))).Should(BeTrue())
+		Ω(m.GroupByCoverage()).Should(ConsistOf(
+			ConsistOf("a"),
+			ConsistOf("b", "c"),
+			ConsistOf("d"),
+			ConsistOf("e"),
+		))
+	})
+
+	It("should free shared sets only once every key is gone", func() {
+		m.ShareCoverage()
+		m.Mask(m, NewIntervalSet(Below(50)))
+		m.SubtractInterval(m, "a", Unbounded())
+		m.SubtractInterval(m, "b", Unbounded())
+		Ω(m.Get("d").Equals(NewIntervalSet(Closed(0, 10), Point(20)))).Should(BeTrue())
+		m.Add(m, "f", Point(70))
+		Ω(m.ValueSlice()).Should(ConsistOf("c", "d", "f"))
+		Ω(m.Get("d").Equals(NewIntervalSet(Closed(0, 10), Point(20)))).Should(BeTrue())
+	})
+})
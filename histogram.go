@@ -0,0 +1,61 @@
+package bandit
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Histogram returns how many uint64 values z covers in each of nBuckets
+// buckets of bucketWidth values, the first starting at origin. Buckets
+// running past the top of the domain only count values within it.
+func (z *IntervalSet) Histogram(origin, bucketWidth uint64, nBuckets int) []uint64 {
+	if nBuckets <= 0 {
+		return []uint64{}
+	}
+	counts := make([]uint64, nBuckets)
+	if bucketWidth == 0 {
+		return counts
+	}
+	window := AtOrAbove(origin)
+	hi, lo := bits.Mul64(bucketWidth, uint64(nBuckets))
+	if end, c := bits.Add64(lo, origin, 0); hi == 0 && c == 0 {
+		window = RightOpen(origin, end)
+	}
+	for it := z.IteratorWithin(window); it.Next(); {
+		lo, hi, ok := it.Interval().integers()
+		if !ok {
+			continue
+		}
+		b := (lo - origin) / bucketWidth
+		start := origin + b*bucketWidth
+		for ; b < uint64(nBuckets); b++ {
+			last := start + (bucketWidth - 1)
+			if last < start {
+				// The bucket runs past the top of the domain
+				last = math.MaxUint64
+			}
+			from, to := start, last
+			if lo > from {
+				from = lo
+			}
+			if hi < to {
+				to = hi
+			}
+			counts[b] += to - from + 1
+			if to == hi {
+				break
+			}
+			start = last + 1
+		}
+	}
+	return counts
+}
+
+// Histogram returns the Histogram of each key's set.
+func (z *IntervalMap) Histogram(origin, bucketWidth uint64, nBuckets int) map[interface{}][]uint64 {
+	out := make(map[interface{}][]uint64, len(z.m))
+	for k, idx := range z.m {
+		out[k] = (&z.sets[idx].IntervalSet).Histogram(origin, bucketWidth, nBuckets)
+	}
+	return out
+}
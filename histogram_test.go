@@ -0,0 +1,41 @@
+package bandit_test
+
+import (
+	"math"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	. "github.com/iancmcc/bandit"
+)
+
+var _ = Describe("Coverage histograms", func() {
+	var a = NewIntervalSet(RightOpen(5, 25), Closed(38, 42))
+
+	DescribeTable("bucketing a set", func(x *IntervalSet, origin, width, n int, expected []uint64) {
+		Ω(x.Histogram(uint64(origin), uint64(width), n)).Should(Equal(expected))
+	},
+		Entry("buckets", a, 0, 10, 5, []uint64{5, 10, 5, 2, 3}),
+		Entry("offset origin", a, 20, 10, 3, []uint64{5, 2, 3}),
+		Entry("one bucket per value", a, 23, 1, 4, []uint64{1, 1, 0, 0}),
+		Entry("open bounds", NewIntervalSet(Open(0, 10)), 0, 5, 2, []uint64{4, 5}),
+		Entry("unbounded", NewIntervalSet(Below(3), Above(6)), 0, 4, 3, []uint64{3, 1, 4}),
+		Entry("no width", a, 0, 0, 2, []uint64{0, 0}),
+		Entry("no buckets", a, 0, 10, 0, []uint64{}),
+	)
+
+	It("should stop counting at the top of the domain", func() {
+		x := NewIntervalSet(Unbounded())
+		Ω(x.Histogram(math.MaxUint64-5, 4, 3)).Should(Equal([]uint64{4, 2, 0}))
+	})
+
+	It("should bucket every key of a map", func() {
+		m := imap("a", Closed(0, 9))
+		m.Add(m, "b", Closed(15, 30))
+		Ω(m.Histogram(0, 10, 3)).Should(Equal(map[interface{}][]uint64{
+			"a": {10, 0, 0},
+			"b": {0, 5, 10},
+		}))
+	})
+})
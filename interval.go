@@ -3,6 +3,8 @@ package bandit
 import (
 	"errors"
 	"fmt"
+	"math"
+	"math/bits"
 )
 
 type (
@@ -80,6 +82,110 @@ func (ival Interval) Upper() uint64 {
 	return ival.nodes[idx].prefix
 }
 
+// Bounds returns the bound types and values of ival, as they would be passed
+// to NewInterval. Unbounded ends report a value of 0.
+func (ival Interval) Bounds() (lowerBound BoundType, lower, upper uint64, upperBound BoundType) {
+	if ival.root == 0 {
+		if ival.ul {
+			return UnboundBound, 0, 0, UnboundBound
+		}
+		return OpenBound, 0, 0, OpenBound
+	}
+	n := &ival.nodes[ival.root]
+	if n.level == 0 {
+		switch {
+		case n.boundBoth():
+			return ClosedBound, n.prefix, n.prefix, ClosedBound
+		case ival.ul && n.incl:
+			return UnboundBound, 0, n.prefix, OpenBound
+		case ival.ul:
+			return UnboundBound, 0, n.prefix, ClosedBound
+		case n.incl:
+			return ClosedBound, n.prefix, 0, UnboundBound
+		}
+		return OpenBound, n.prefix, 0, UnboundBound
+	}
+	l, r := &ival.nodes[n.left], &ival.nodes[n.right]
+	lowerBound, upperBound = OpenBound, ClosedBound
+	if l.incl {
+		lowerBound = ClosedBound
+	}
+	if r.incl {
+		upperBound = OpenBound
+	}
+	return lowerBound, l.prefix, r.prefix, upperBound
+}
+
+// integers returns the least and greatest uint64 values in ival, and
+// whether there are any at all.
+func (ival Interval) integers() (lo, hi uint64, ok bool) {
+	if ival.IsEmpty() {
+		return 0, 0, false
+	}
+	return integerRange(ival.Bounds())
+}
+
+// integerRange returns the least and greatest uint64 values within the
+// given bounds, and whether there are any at all.
+func integerRange(lowerBound BoundType, lower, upper uint64, upperBound BoundType) (lo, hi uint64, ok bool) {
+	lo, hi = lower, upper
+	switch lowerBound {
+	case UnboundBound:
+		lo = 0
+	case OpenBound:
+		if lo == math.MaxUint64 {
+			return 0, 0, false
+		}
+		lo += 1
+	}
+	switch upperBound {
+	case UnboundBound:
+		hi = math.MaxUint64
+	case OpenBound:
+		if hi == 0 {
+			return 0, 0, false
+		}
+		hi -= 1
+	}
+	return lo, hi, lo <= hi
+}
+
+// span returns the length of the interval with the given bounds, counting
+// the uint64 values it holds if discrete, and false if it is unbounded.
+func span(discrete bool, lowerBound BoundType, lower, upper uint64, upperBound BoundType) (uint64, bool) {
+	switch {
+	case lowerBound == UnboundBound, upperBound == UnboundBound:
+		return 0, false
+	case !discrete:
+		return upper - lower, true
+	}
+	lo, hi, ok := integerRange(lowerBound, lower, upper, upperBound)
+	switch {
+	case !ok:
+		return 0, true
+	case hi-lo == math.MaxUint64:
+		return math.MaxUint64, true
+	}
+	return hi - lo + 1, true
+}
+
+// Normalize returns the closed interval holding the same uint64 values as
+// ival, or an empty interval if it holds none.
+func (ival Interval) Normalize() Interval {
+	lo, hi, ok := ival.integers()
+	if !ok {
+		return Empty()
+	}
+	return Closed(lo, hi)
+}
+
+// detach returns ival with its nodes moved into its own array, so that it
+// no longer shares storage with an iterator.
+func (ival Interval) detach() Interval {
+	ival.nodes = ival.array[:copy(ival.array[:], ival.nodes)]
+	return ival
+}
+
 func (ival Interval) AsIntervalSet() *IntervalSet {
 	copy(ival.array[:], ival.nodes)
 	ival.nodes = ival.array[:len(ival.nodes)]
@@ -128,6 +234,107 @@ func (ival Interval) Union(other Interval) Interval {
 	return ival
 }
 
+// Add returns every sum of a value in ival and a value in other. A sum is
+// open wherever either of its terms is, and saturates at MaxUint64. As with
+// Mul, values below 0 don't count, so only an unbounded upper end carries
+// over to the sum.
+func (ival Interval) Add(other Interval) Interval {
+	ival, other = ival.Intersection(AtOrAbove(0)), other.Intersection(AtOrAbove(0))
+	if ival.IsEmpty() || other.IsEmpty() {
+		return Empty()
+	}
+	alb, alower, aupper, aub := ival.Bounds()
+	blb, blower, bupper, bub := other.Bounds()
+	lb, lower := addBounds(alb, alower, blb, blower)
+	ub, upper := addBounds(aub, aupper, bub, bupper)
+	return NewInterval(lb, lower, upper, ub)
+}
+
+// Sub returns every difference of a value in ival and a value in other,
+// saturating at 0. Values below 0 don't count, so the difference is
+// unbounded above only if ival is, and saturates at 0 if other is.
+func (ival Interval) Sub(other Interval) Interval {
+	ival, other = ival.Intersection(AtOrAbove(0)), other.Intersection(AtOrAbove(0))
+	if ival.IsEmpty() || other.IsEmpty() {
+		return Empty()
+	}
+	alb, alower, aupper, aub := ival.Bounds()
+	blb, blower, bupper, bub := other.Bounds()
+	lb, lower := subBounds(alb, alower, bub, bupper, false)
+	ub, upper := subBounds(aub, aupper, blb, blower, true)
+	return NewInterval(lb, lower, upper, ub)
+}
+
+// Mul returns every product of a value in ival and a value in other,
+// saturating at MaxUint64. Both are taken to hold no values below 0, so an
+// unbounded lower bound counts as a closed one at 0.
+func (ival Interval) Mul(other Interval) Interval {
+	ival, other = ival.Intersection(AtOrAbove(0)), other.Intersection(AtOrAbove(0))
+	switch {
+	case ival.IsEmpty(), other.IsEmpty():
+		return Empty()
+	case ival.Equals(Point(0)), other.Equals(Point(0)):
+		// Zero times anything, even an unbounded interval
+		return Point(0)
+	}
+	alb, alower, aupper, aub := ival.Bounds()
+	blb, blower, bupper, bub := other.Bounds()
+	lb, lower := mulBounds(alb, alower, blb, blower)
+	ub, upper := mulBounds(aub, aupper, bub, bupper)
+	return NewInterval(lb, lower, upper, ub)
+}
+
+// addBounds adds two bounds on the same side of their intervals, neither of
+// which is an unbounded lower one.
+func addBounds(at BoundType, a uint64, bt BoundType, b uint64) (BoundType, uint64) {
+	switch {
+	case at == UnboundBound, bt == UnboundBound:
+		return UnboundBound, 0
+	case a > math.MaxUint64-b:
+		return ClosedBound, math.MaxUint64
+	case at == OpenBound, bt == OpenBound:
+		return OpenBound, a + b
+	}
+	return ClosedBound, a + b
+}
+
+// subBounds subtracts the bound b from a, which lie on opposite sides of
+// their intervals, and neither of which is an unbounded lower one.
+// Differences below 0 saturate there, as does an upper bound that excludes
+// 0 itself.
+func subBounds(at BoundType, a uint64, bt BoundType, b uint64, upper bool) (BoundType, uint64) {
+	open := at == OpenBound || bt == OpenBound
+	switch {
+	case at == UnboundBound:
+		return UnboundBound, 0
+	case bt == UnboundBound:
+		// Nothing is left of a once an unbounded b is taken away
+		return ClosedBound, 0
+	case a < b, upper && a == b && open:
+		return ClosedBound, 0
+	case open:
+		return OpenBound, a - b
+	}
+	return ClosedBound, a - b
+}
+
+// mulBounds multiplies two bounds on the same side of their intervals.
+func mulBounds(at BoundType, a uint64, bt BoundType, b uint64) (BoundType, uint64) {
+	hi, lo := bits.Mul64(a, b)
+	switch {
+	case at == UnboundBound, bt == UnboundBound:
+		return UnboundBound, 0
+	case (a == 0 && at == ClosedBound) || (b == 0 && bt == ClosedBound):
+		// A factor of exactly 0 is reached no matter the other bound
+		return ClosedBound, 0
+	case hi != 0:
+		return ClosedBound, math.MaxUint64
+	case at == OpenBound, bt == OpenBound:
+		return OpenBound, lo
+	}
+	return ClosedBound, lo
+}
+
 func (ival Interval) IsEmpty() bool {
 	return ival.root == 0 && !ival.ul
 }
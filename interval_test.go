@@ -2,6 +2,7 @@ package bandit_test
 
 import (
 	"fmt"
+	"math"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
@@ -157,6 +158,61 @@ var _ = Describe("Interval", func() {
 
 	})
 
+	DescribeTable("reporting bounds", func(ival Interval, lowerBound BoundType, lower, upper int, upperBound BoundType) {
+		lb, l, u, ub := ival.Bounds()
+		Ω(lb).Should(Equal(lowerBound))
+		Ω(l).Should(BeNumerically("==", lower))
+		Ω(u).Should(BeNumerically("==", upper))
+		Ω(ub).Should(Equal(upperBound))
+		Ω(NewInterval(lb, l, u, ub).Equals(ival)).Should(BeTrue())
+	},
+		Entry("closed", Closed(1, 8), ClosedBound, 1, 8, ClosedBound),
+		Entry("open", Open(1, 8), OpenBound, 1, 8, OpenBound),
+		Entry("left-open", LeftOpen(1, 8), OpenBound, 1, 8, ClosedBound),
+		Entry("right-open", RightOpen(1, 8), ClosedBound, 1, 8, OpenBound),
+		Entry("point", Point(3), ClosedBound, 3, 3, ClosedBound),
+		Entry("above", Above(3), OpenBound, 3, 0, UnboundBound),
+		Entry("at or above", AtOrAbove(3), ClosedBound, 3, 0, UnboundBound),
+		Entry("below", Below(3), UnboundBound, 0, 3, OpenBound),
+		Entry("at or below", AtOrBelow(3), UnboundBound, 0, 3, ClosedBound),
+		Entry("unbounded", Unbounded(), UnboundBound, 0, 0, UnboundBound),
+		Entry("empty", Empty(), OpenBound, 0, 0, OpenBound),
+	)
+
+	DescribeTable("interval arithmetic", func(op string, a, b, expected Interval) {
+		var actual Interval
+		switch op {
+		case "+":
+			actual = a.Add(b)
+		case "-":
+			actual = a.Sub(b)
+		case "*":
+			actual = a.Mul(b)
+		}
+		Ω(actual.Equals(expected)).Should(BeTrue(), "%s %s %s = %s, not %s", a, op, b, actual, expected)
+	},
+		Entry("closed sum", "+", Closed(1, 2), Closed(10, 20), Closed(11, 22)),
+		Entry("open + closed", "+", LeftOpen(1, 2), RightOpen(10, 20), Open(11, 22)),
+		Entry("sum with empty", "+", Closed(1, 2), Empty(), Empty()),
+		Entry("unbounded sum", "+", Below(5), AtOrAbove(10), AtOrAbove(10)),
+		Entry("sum unbounded below", "+", AtOrBelow(5), Point(3), Closed(3, 8)),
+		Entry("sum saturating", "+", Open(10, 20), Open(math.MaxUint64-15, math.MaxUint64-5), LeftOpen(math.MaxUint64-5, math.MaxUint64)),
+		Entry("difference", "-", Closed(10, 20), RightOpen(1, 5), LeftOpen(5, 19)),
+		Entry("difference saturating", "-", Closed(10, 20), Closed(15, 30), Closed(0, 5)),
+		Entry("difference touching 0", "-", Open(10, 20), Closed(0, 10), Open(0, 20)),
+		Entry("difference straddling 0", "-", Open(10, 20), Closed(10, 20), RightOpen(0, 10)),
+		Entry("difference below 0", "-", RightOpen(5, 10), Closed(10, 20), Point(0)),
+		Entry("difference from unbounded", "-", AtOrAbove(10), Closed(1, 2), AtOrAbove(8)),
+		Entry("difference of unbounded", "-", Closed(10, 20), AtOrAbove(5), Closed(0, 15)),
+		Entry("difference of unbounded below", "-", Closed(5, 10), AtOrBelow(3), Closed(2, 10)),
+		Entry("difference from unbounded below", "-", Below(5), Closed(1, 2), RightOpen(0, 4)),
+		Entry("product", "*", Closed(2, 3), Open(10, 20), Open(20, 60)),
+		Entry("product with 0", "*", Closed(0, 3), Open(10, 20), RightOpen(0, 60)),
+		Entry("product of 0", "*", Point(0), AtOrAbove(10), Point(0)),
+		Entry("product of unbounded", "*", Below(3), Closed(2, 4), RightOpen(0, 12)),
+		Entry("product saturating", "*", Closed(2, 3), AtOrBelow(math.MaxUint64/2), Closed(0, math.MaxUint64)),
+	)
+
 	Context("Comparing Intervals", func() {
 		DescribeTable("It should report equality correctly", func(intv1, intv2 Interval, eq bool) {
 			s := "equal"
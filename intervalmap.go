@@ -2,19 +2,23 @@ package bandit
 
 import (
 	"fmt"
+	"math/big"
 	"strings"
 )
 
 type (
 	setnode struct {
 		IntervalSet
-		ptr uint
+		ptr  uint
+		refs uint // Keys sharing the set beyond the first
 	}
 	IntervalMap struct {
 		m        map[interface{}]uint // TODO: Make this more GC-friendly
 		ncap     uint
 		numfree  uint
 		nextfree uint
+		discrete bool
+		index    *keyIndex
 		sets     []setnode
 	}
 )
@@ -34,7 +38,7 @@ func NewIntervalMapWithCapacity(mapCapacity, nodeArraySize int) *IntervalMap {
 }
 
 func CopyMap(x *IntervalMap) *IntervalMap {
-	return NewMap(x.Caps()).Copy(x)
+	return NewMap(x.Caps()).SetDiscrete(x.discrete).SetIndexed(x.IsIndexed()).Copy(x)
 }
 
 func NewMap(mc, nc int) *IntervalMap {
@@ -53,6 +57,22 @@ func (z *IntervalMap) Caps() (int, int) {
 	return cap(z.sets), int(z.ncap)
 }
 
+// SetDiscrete turns discrete mode on or off for every set in z, and for
+// those added later. See IntervalSet.SetDiscrete. Keys left with no uint64
+// values are dropped.
+func (z *IntervalMap) SetDiscrete(discrete bool) *IntervalMap {
+	z.discrete = discrete
+	for k, idx := range z.m {
+		(&z.sets[idx].IntervalSet).SetDiscrete(discrete)
+		z.store(k, idx)
+	}
+	return z
+}
+
+func (z *IntervalMap) IsDiscrete() bool {
+	return z.discrete
+}
+
 func (z *IntervalMap) IsEmpty() bool {
 	return len(z.m) == 0
 }
@@ -80,7 +100,7 @@ func (z *IntervalMap) Equals(x *IntervalMap) bool {
 }
 
 func CopySet(x *IntervalSet) *IntervalSet {
-	return NewSet(x.Cap()).Copy(x)
+	return NewSet(x.Cap()).SetDiscrete(x.discrete).Copy(x)
 }
 
 func (z *IntervalMap) allocset(x *IntervalSet, maxcap uint) (idx uint) {
@@ -99,21 +119,58 @@ func (z *IntervalMap) allocset(x *IntervalSet, maxcap uint) (idx uint) {
 		} else {
 			x = CopySet(x)
 		}
-		z.sets = append(z.sets, setnode{*x, 0})
+		z.sets = append(z.sets, setnode{IntervalSet: *x})
 		idx = uint(len(z.sets) - 1)
 	}
+	(&z.sets[idx].IntervalSet).SetDiscrete(z.discrete)
 	return
 }
 
+// put stores a copy of x under k, unless the copy turns out to be empty.
+func (z *IntervalMap) put(k interface{}, x *IntervalSet) {
+	z.store(k, z.allocset(x, 0))
+}
+
+// store files the set at idx under k, or drops k and frees the set if it
+// has no members. Every change to a key's set ends here or in remove, which
+// keep the index up to date.
+func (z *IntervalMap) store(k interface{}, idx uint) {
+	if (&z.sets[idx].IntervalSet).IsEmpty() {
+		z.free(idx)
+		delete(z.m, k)
+	} else {
+		z.m[k] = idx
+	}
+	z.touch(k)
+}
+
 func (z *IntervalMap) free(idx uint) {
 	if idx == 0 {
 		return
 	}
+	if s := &z.sets[idx]; s.refs > 0 {
+		// Another key still holds the set
+		s.refs -= 1
+		return
+	}
 	z.sets[idx] = setnode{ptr: z.nextfree}
 	z.nextfree = idx
 	z.numfree += 1
 }
 
+// own gives k a set of its own if it shares one with other keys, returning
+// the set's index.
+func (z *IntervalMap) own(k interface{}) uint {
+	idx := z.m[k]
+	if z.sets[idx].refs == 0 {
+		return idx
+	}
+	z.sets[idx].refs -= 1
+	nidx := z.allocset(&z.sets[idx].IntervalSet, 0)
+	z.store(k, nidx)
+	return nidx
+}
+
 func (z *IntervalMap) Clear() {
 	z.sets = z.sets[:1]
 	z.nextfree = 0
@@ -122,6 +179,9 @@ func (z *IntervalMap) Clear() {
 	for k := range z.m {
 		delete(z.m, k)
 	}
+	if z.index != nil {
+		z.index.clear()
+	}
 }
 
 func (z *IntervalMap) Copy(x *IntervalMap) *IntervalMap {
@@ -133,7 +193,7 @@ func (z *IntervalMap) Copy(x *IntervalMap) *IntervalMap {
 		return z
 	}
 	for k, v := range x.m {
-		z.m[k] = z.allocset(&x.sets[v].IntervalSet, 0)
+		z.put(k, &x.sets[v].IntervalSet)
 	}
 	return z
 }
@@ -150,15 +210,14 @@ func (z *IntervalMap) Add(x *IntervalMap, val interface{}, ival ...Interval) *In
 		z.Copy(x)
 	}
 	idx, ok := z.m[val]
-	if !ok {
+	if ok {
+		idx = z.own(val)
+	} else {
 		idx = z.allocset(nil, uint(len(ival)))
-		z.m[val] = idx
 	}
 	set := &z.sets[idx].IntervalSet
 	set.Add(set, ival...)
-	if set.IsEmpty() {
-		z.remove(val)
-	}
+	z.store(val, idx)
 	return z
 }
 
@@ -172,17 +231,15 @@ func (z *IntervalMap) AddSet(x *IntervalMap, val interface{}, iset *IntervalSet)
 	if iset == nil || iset.IsEmpty() {
 		return z
 	}
-	idx, ok := z.m[val]
+	_, ok := z.m[val]
 	if !ok {
-		idx = z.allocset(iset, 0)
-		z.m[val] = idx
+		z.put(val, iset)
 		return z
 	}
+	idx := z.own(val)
 	set := &z.sets[idx].IntervalSet
 	set.Union(set, iset)
-	if set.IsEmpty() {
-		z.remove(val)
-	}
+	z.store(val, idx)
 	return z
 }
 
@@ -202,14 +259,14 @@ func (z *IntervalMap) Intersection(x *IntervalMap, y *IntervalMap) *IntervalMap
 		} else {
 			other = x
 		}
-		for k, zidx := range z.m {
+		for k := range z.m {
 			if oidx, ok := other.m[k]; !ok {
 				z.remove(k)
 			} else {
+				zidx := z.own(k)
 				zset, oset := &z.sets[zidx].IntervalSet, &other.sets[oidx].IntervalSet
-				if zset.Intersection(zset, oset).IsEmpty() {
-					z.remove(k)
-				}
+				zset.Intersection(zset, oset)
+				z.store(k, zidx)
 			}
 		}
 		return z
@@ -235,11 +292,8 @@ func (z *IntervalMap) Intersection(x *IntervalMap, y *IntervalMap) *IntervalMap
 		aset, bset = &smaller.sets[aidx].IntervalSet, &larger.sets[bidx].IntervalSet
 		zidx = z.allocset(nil, 0)
 		zset = &z.sets[zidx].IntervalSet
-		if zset.Intersection(aset, bset).IsEmpty() {
-			z.remove(zidx)
-			continue
-		}
-		z.m[k] = zidx
+		zset.Intersection(aset, bset)
+		z.store(k, zidx)
 	}
 	return z
 }
@@ -263,13 +317,14 @@ func (z *IntervalMap) Union(x *IntervalMap, y *IntervalMap) *IntervalMap {
 		}
 		for k, oidx := range other.m {
 			oset := &other.sets[oidx].IntervalSet
-			zidx, ok := z.m[k]
-			if !ok {
-				z.m[k] = z.allocset(oset, 0)
+			if _, ok := z.m[k]; !ok {
+				z.put(k, oset)
 				continue
 			}
+			zidx := z.own(k)
 			zset := &z.sets[zidx].IntervalSet
 			zset.Union(zset, oset)
+			z.store(k, zidx)
 		}
 	}
 	return z
@@ -282,6 +337,7 @@ func (z *IntervalMap) remove(k interface{}) bool {
 	}
 	z.free(idx)
 	delete(z.m, k)
+	z.touch(k)
 	return true
 }
 
@@ -306,23 +362,21 @@ func (z *IntervalMap) SymmetricDifference(x *IntervalMap, y *IntervalMap) *Inter
 		}
 		for k, oidx := range other.m {
 			oset := &other.sets[oidx].IntervalSet
-			zidx, ok := z.m[k]
-			if !ok {
-				z.m[k] = z.allocset(oset, 0)
+			if _, ok := z.m[k]; !ok {
+				z.put(k, oset)
 				continue
 			}
+			zidx := z.own(k)
 			zset := &z.sets[zidx].IntervalSet
 			zset.SymmetricDifference(zset, oset)
-			if zset.IsEmpty() {
-				z.remove(k)
-			}
+			z.store(k, zidx)
 		}
 	}
 	return z
 }
 
 func (z *IntervalMap) AllIntervals() *IntervalSet {
-	s := NewIntervalSetWithCapacity(z.ncap)
+	s := NewIntervalSetWithCapacity(z.ncap).SetDiscrete(z.discrete)
 	for _, sidx := range z.m {
 		set := &z.sets[sidx].IntervalSet
 		s.Union(s, set)
@@ -330,6 +384,15 @@ func (z *IntervalMap) AllIntervals() *IntervalSet {
 	return s
 }
 
+// MeasureByKey returns the number of uint64 values covered by each key.
+func (z *IntervalMap) MeasureByKey() map[interface{}]*big.Int {
+	out := make(map[interface{}]*big.Int, len(z.m))
+	for k, idx := range z.m {
+		out[k] = (&z.sets[idx].IntervalSet).Measure()
+	}
+	return out
+}
+
 func (z *IntervalMap) MutateValues(x *IntervalMap, f func(interface{}) interface{}) *IntervalMap {
 	z.Copy(x)
 	seen := make(map[interface{}]struct{})
@@ -343,19 +406,23 @@ func (z *IntervalMap) MutateValues(x *IntervalMap, f func(interface{}) interface
 			z.remove(k)
 			continue
 		}
-		if existing, ok := z.m[nv]; ok {
+		if _, ok := z.m[nv]; ok {
 			// Merge existing
-			x1 := &z.sets[existing].IntervalSet
+			nidx := z.own(nv)
+			x1 := &z.sets[nidx].IntervalSet
 			x1.Union(x1, &z.sets[idx].IntervalSet)
 			if k != nv {
 				z.remove(k)
 			}
+			z.store(nv, nidx)
 			continue
 		}
 		if nv != k {
-			z.m[nv] = idx
+			// Hand the set over to nv before k lets go of it
+			z.sets[idx].refs += 1
+			z.store(nv, idx)
 			seen[nv] = struct{}{}
-			delete(z.m, k)
+			z.remove(k)
 		}
 	}
 	return z
@@ -368,15 +435,15 @@ func (z *IntervalMap) Mask(x *IntervalMap, mask *IntervalSet) *IntervalMap {
 	for k, idx := range x.m {
 		s := &x.sets[idx].IntervalSet
 		if z == x {
+			zidx := z.own(k)
+			s = &z.sets[zidx].IntervalSet
 			s.Intersection(s, mask)
+			z.store(k, zidx)
 			continue
 		}
 		didx := z.allocset(nil, 0)
-		if (&z.sets[didx].IntervalSet).Intersection(s, mask).IsEmpty() {
-			z.free(didx)
-			continue
-		}
-		z.m[k] = didx
+		(&z.sets[didx].IntervalSet).Intersection(s, mask)
+		z.store(k, didx)
 	}
 	return z
 }
@@ -385,14 +452,13 @@ func (z *IntervalMap) SubtractInterval(x *IntervalMap, value interface{}, ival I
 	if z != x {
 		z.Copy(x)
 	}
-	idx, ok := z.m[value]
-	if !ok {
+	if _, ok := z.m[value]; !ok {
 		return z
 	}
+	idx := z.own(value)
 	set := &z.sets[idx].IntervalSet
-	if set.Difference(set, ival.AsIntervalSet()).IsEmpty() {
-		z.remove(value)
-	}
+	set.Difference(set, ival.AsIntervalSet())
+	z.store(value, idx)
 	return z
 }
 
@@ -424,30 +490,29 @@ func (z *IntervalMap) Difference(x *IntervalMap, y *IntervalMap) *IntervalMap {
 		x = z
 		fallthrough
 	case z == x:
-		for k, zidx := range z.m {
+		for k := range z.m {
 			yidx, ok := y.m[k]
 			if !ok {
 				continue
 			}
+			zidx := z.own(k)
 			zset := &z.sets[zidx].IntervalSet
 			yset := &y.sets[yidx].IntervalSet
-			if zset.Difference(zset, yset).IsEmpty() {
-				z.remove(k)
-			}
+			zset.Difference(zset, yset)
+			z.store(k, zidx)
 		}
 	case z == y:
 		fmt.Println("2")
 		for k, xidx := range x.m {
 			xset := &x.sets[xidx].IntervalSet
-			zidx, ok := z.m[k]
-			if !ok {
-				z.m[k] = z.allocset(xset, 0)
+			if _, ok := z.m[k]; !ok {
+				z.put(k, xset)
 				continue
 			}
+			zidx := z.own(k)
 			zset := &z.sets[zidx].IntervalSet
-			if zset.Difference(xset, zset).IsEmpty() {
-				z.remove(k)
-			}
+			zset.Difference(xset, zset)
+			z.store(k, zidx)
 		}
 	}
 	return z
@@ -1,6 +1,8 @@
 package bandit_test
 
 import (
+	"fmt"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
@@ -54,6 +56,79 @@ var _ = Describe("IntervalMap", func() {
 		Entry("(1, ∞) - ]1[", "-", d, imap("", Empty())),
 	)
 
+	It("should drop keys emptied by a mask", func() {
+		a := imap("a", Closed(1, 10))
+		a.Add(a, "b", Closed(20, 30))
+		a.Mask(a, NewIntervalSet(Closed(0, 15)))
+		Ω(a.ValueSlice()).Should(ConsistOf("a"))
+	})
+
+	It("should drop keys emptied by discrete mode", func() {
+		a := imap("a", Open(1, 2))
+		a.Add(a, "b", Closed(20, 30))
+		a.SetDiscrete(true)
+		Ω(a.ValueSlice()).Should(ConsistOf("b"))
+	})
+
+	Context("finding keys", func() {
+		build := func(indexed bool) *IntervalMap {
+			m := NewIntervalMap().SetIndexed(indexed)
+			m.Add(m, "a", Closed(0, 10))
+			m.Add(m, "b", Open(5, 20), Point(40))
+			m.Add(m, "c", AtOrAbove(100))
+			m.Add(m, "d", Below(3), Above(30))
+			return m
+		}
+
+		for _, indexed := range []bool{false, true} {
+			indexed := indexed
+
+			DescribeTable(fmt.Sprintf("at a value (indexed: %t)", indexed), func(x int, expected ...interface{}) {
+				Ω(build(indexed).KeysAt(uint64(x))).Should(ConsistOf(expected...))
+			},
+				Entry("start of a key", 0, "a", "d"),
+				Entry("open bound", 5, "a"),
+				Entry("overlap", 6, "a", "b"),
+				Entry("point", 40, "b", "d"),
+				Entry("nothing", 25),
+				Entry("unbounded", 1000, "c", "d"),
+			)
+
+			DescribeTable(fmt.Sprintf("overlapping an interval (indexed: %t)", indexed), func(ival Interval, expected ...interface{}) {
+				Ω(build(indexed).KeysOverlapping(ival)).Should(ConsistOf(expected...))
+			},
+				Entry("touching open bounds", Closed(20, 30)),
+				Entry("spanning keys", Closed(10, 100), "a", "b", "c", "d"),
+				Entry("between keys", Open(20, 30)),
+				Entry("empty", Empty()),
+				Entry("unbounded", Above(50), "c", "d"),
+			)
+		}
+
+		It("should keep the index up to date", func() {
+			m := build(true)
+			m.SubtractInterval(m, "a", Closed(0, 10))
+			m.Add(m, "e", Point(25))
+			m.Mask(m, NewIntervalSet(Below(200)))
+			m.MutateValues(m, func(k interface{}) interface{} {
+				if k == "c" {
+					return "f"
+				}
+				return k
+			})
+			Ω(m.KeysAt(2)).Should(ConsistOf("d"))
+			Ω(m.KeysAt(25)).Should(ConsistOf("e"))
+			Ω(m.KeysAt(150)).Should(ConsistOf("d", "f"))
+			Ω(m.KeysAt(250)).Should(BeEmpty())
+			m.Clear()
+			Ω(m.KeysAt(2)).Should(BeEmpty())
+			Ω(m.IsIndexed()).Should(BeTrue())
+			m.Add(m, "g", Closed(1, 3))
+			Ω(m.KeysAt(2)).Should(ConsistOf("g"))
+			Ω(m.KeysOverlapping(Above(2))).Should(ConsistOf("g"))
+		})
+	})
+
 	operatorTest := func(astr string, operator string, bstr string, expectedstr ...string) {
 		ivals := make([]Interval, len(expectedstr))
 		for i, s := range expectedstr {
@@ -1,6 +1,9 @@
 package bandit
 
 import (
+	"math"
+	"math/big"
+	"math/bits"
 	"strings"
 )
 
@@ -37,6 +40,29 @@ func newIntervalSetWithNodeStorage(storage []node, intervals ...Interval) Interv
 	return set
 }
 
+// SetDiscrete turns discrete mode on or off. A discrete set only concerns
+// itself with uint64 values, so after every operation it rewrites each
+// interval in closed form and merges intervals that leave no integer
+// between them. Open(1, 5) and Closed(2, 4) then compare equal, and
+// Above(math.MaxUint64) is empty.
+func (z *IntervalSet) SetDiscrete(discrete bool) *IntervalSet {
+	z.discrete = discrete
+	return z.normalize()
+}
+
+func (z *IntervalSet) IsDiscrete() bool {
+	return z.discrete
+}
+
+// normalize rewrites z in canonical discrete form if it is in discrete
+// mode, leaving the tree alone if it is already canonical.
+func (z *IntervalSet) normalize() *IntervalSet {
+	if z.discrete {
+		z.canonicalize()
+	}
+	return z
+}
+
 func (z *IntervalSet) Cap() int {
 	return cap(z.nodes)
 }
@@ -45,6 +71,374 @@ func (z *IntervalSet) Iterator() *IntervalIterator {
 	return NewIntervalIterator(z.Tree)
 }
 
+// IteratorWithin returns an iterator over the intervals of z that overlap
+// bounds, clipped to bounds.
+func (z *IntervalSet) IteratorWithin(bounds Interval) *IntervalIterator {
+	return newIntervalIteratorWithin(z.Tree, bounds, z.discrete)
+}
+
+// GapIterator returns an iterator over the parts of bounds that z doesn't
+// cover, in ascending order.
+func (z *IntervalSet) GapIterator(bounds Interval) *IntervalIterator {
+	return z.gapIterator(bounds, z.discrete)
+}
+
+func (z *IntervalSet) gapIterator(bounds Interval, discrete bool) *IntervalIterator {
+	t := z.Tree
+	// Flipping membership below every boundary complements the tree
+	t.ul = !t.ul
+	return newIntervalIteratorWithin(t, bounds, discrete)
+}
+
+// Gaps returns the parts of bounds that z doesn't cover.
+func (z *IntervalSet) Gaps(bounds Interval) []Interval {
+	var gaps []Interval
+	for it := z.GapIterator(bounds); it.Next(); {
+		gaps = append(gaps, it.Interval().detach())
+	}
+	return gaps
+}
+
+// Split returns the members of z below at, and those at or above it.
+func (z *IntervalSet) Split(at uint64) (below, above *IntervalSet) {
+	below = NewIntervalSet(Below(at)).SetDiscrete(z.discrete)
+	above = NewIntervalSet(AtOrAbove(at)).SetDiscrete(z.discrete)
+	return below.Intersection(below, z), above.Intersection(above, z)
+}
+
+// remap sets z to the union of f applied to each interval of x, and
+// returns z. f must keep intervals in order, so that no image starts
+// before the one ahead of it.
+func (z *IntervalSet) remap(x *IntervalSet, f func(lowerBound BoundType, lower, upper uint64, upperBound BoundType) Interval) *IntervalSet {
+	z, _ = z.tryRemap(x, func(lb BoundType, lower, upper uint64, ub BoundType) (Interval, error) {
+		return f(lb, lower, upper, ub), nil
+	})
+	return z
+}
+
+// tryRemap is like remap, but stops at the first error from f, leaving z
+// as it was. Images are merged as they arrive, in a single pass, and the
+// tree is built from the resulting leaves in one go.
+func (z *IntervalSet) tryRemap(x *IntervalSet, f func(lowerBound BoundType, lower, upper uint64, upperBound BoundType) (Interval, error)) (*IntervalSet, error) {
+	if x == nil {
+		z.Clear()
+		return z, nil
+	}
+	var (
+		out    = Tree{nodes: make([]node, 1, len(x.nodes))}
+		leaves = make([]node, 0, x.nodes[x.root].count+2)
+		// The image still open to merging with those after it
+		pending     bool
+		plb, pub    BoundType
+		plow, phigh uint64
+		err         error
+	)
+	// flush adds the leaves bounding the pending image
+	flush := func() {
+		switch n := len(leaves); {
+		case plb == UnboundBound:
+			out.ul = true
+		case plb == ClosedBound && pub == ClosedBound && plow == phigh:
+			leaves = append(leaves, node{prefix: plow, incl: true})
+			return
+		case plb == ClosedBound:
+			leaves = append(leaves, node{prefix: plow, incl: true, ul: true})
+		case n > 0 && leaves[n-1].prefix == plow:
+			// The last image ended open where this one starts open
+			leaves[n-1].ul = false
+		default:
+			leaves = append(leaves, node{prefix: plow, ul: true})
+		}
+		switch pub {
+		case ClosedBound:
+			leaves = append(leaves, node{prefix: phigh, ul: true})
+		case OpenBound:
+			leaves = append(leaves, node{prefix: phigh, incl: true, ul: true})
+		}
+	}
+	x.eachInterval(func(lb BoundType, lower, upper uint64, ub BoundType) bool {
+		var ival Interval
+		if ival, err = f(lb, lower, upper, ub); err != nil {
+			return false
+		}
+		if ival.IsEmpty() {
+			return true
+		}
+		lb, lower, upper, ub = ival.Bounds()
+		if pending && touches(pub, phigh, lower, lb) {
+			if extends(ub, upper, pub, phigh) {
+				pub, phigh = ub, upper
+			}
+			return true
+		}
+		if pending {
+			flush()
+		}
+		pending = true
+		plb, plow, phigh, pub = lb, lower, upper, ub
+		return true
+	})
+	if err != nil {
+		return z, err
+	}
+	if pending {
+		flush()
+	}
+	if len(leaves) > 0 {
+		out.root, _ = out.build(leaves, 65)
+	}
+	out.discrete = z.discrete
+	z.Tree = out
+	return z.normalize(), nil
+}
+
+// reshape sets z to the union of f applied to each interval of x, and
+// returns z. f must keep intervals in order, so that no image starts
+// before the one ahead of it. Rather than rebuilding z, it edits a copy of
+// x in place: it toggles out the boundaries of each interval as the walk
+// passes it, and toggles in those of each run of images that touch or
+// overlap once the run is complete.
+func (z *IntervalSet) reshape(x *IntervalSet, f func(lowerBound BoundType, lower, upper uint64, upperBound BoundType) Interval) *IntervalSet {
+	if x == nil {
+		z.Clear()
+		return z
+	}
+	var (
+		src      = x.Tree
+		discrete = z.discrete
+		// The run of images still open to merging with those after it
+		pending     bool
+		plb, pub    BoundType
+		plow, phigh uint64
+	)
+	// Boundaries come and go one at a time, so canonical form has to wait
+	// until the end
+	z.discrete = false
+	if z == x {
+		// Walk the intervals as they were before any edits
+		src.nodes = append([]node(nil), x.nodes...)
+	} else {
+		z.Copy(x)
+	}
+	src.eachInterval(func(lb BoundType, lower, upper uint64, ub BoundType) bool {
+		z.toggleBounds(lb, lower, upper, ub)
+		ival := f(lb, lower, upper, ub)
+		if ival.IsEmpty() {
+			return true
+		}
+		lb, lower, upper, ub = ival.Bounds()
+		if pending && touches(pub, phigh, lower, lb) {
+			if extends(ub, upper, pub, phigh) {
+				pub, phigh = ub, upper
+			}
+			return true
+		}
+		if pending {
+			z.toggleBounds(plb, plow, phigh, pub)
+		}
+		pending = true
+		plb, plow, phigh, pub = lb, lower, upper, ub
+		return true
+	})
+	if pending {
+		z.toggleBounds(plb, plow, phigh, pub)
+	}
+	z.discrete = discrete
+	return z.normalize()
+}
+
+// touches reports whether an interval ending at upper meets or overlaps
+// one starting at lower, no earlier than its own start.
+func touches(ub BoundType, upper, lower uint64, lb BoundType) bool {
+	switch {
+	case ub == UnboundBound, lb == UnboundBound:
+		return true
+	case upper == lower:
+		return ub != OpenBound || lb != OpenBound
+	}
+	return upper > lower
+}
+
+// extends reports whether an interval ending at upper reaches past one
+// ending at end.
+func extends(ub BoundType, upper uint64, eb BoundType, end uint64) bool {
+	switch {
+	case eb == UnboundBound:
+		return false
+	case ub == UnboundBound:
+		return true
+	case upper == end:
+		return ub == ClosedBound && eb == OpenBound
+	}
+	return upper > end
+}
+
+// Dilate sets z to x with every interval grown by d at each bounded end,
+// saturating at 0 and MaxUint64, and returns z. Intervals that come to
+// overlap merge.
+func (z *IntervalSet) Dilate(x *IntervalSet, d uint64) *IntervalSet {
+	return z.reshape(x, func(lb BoundType, lower, upper uint64, ub BoundType) Interval {
+		if lb != UnboundBound {
+			if lower < d {
+				lb, lower = ClosedBound, 0
+			} else {
+				lower -= d
+			}
+		}
+		if ub != UnboundBound {
+			if upper > math.MaxUint64-d {
+				ub, upper = ClosedBound, math.MaxUint64
+			} else {
+				upper += d
+			}
+		}
+		return NewInterval(lb, lower, upper, ub)
+	})
+}
+
+// Erode sets z to x with every interval shrunk by d at each bounded end,
+// dropping those that vanish, and returns z.
+func (z *IntervalSet) Erode(x *IntervalSet, d uint64) *IntervalSet {
+	return z.reshape(x, func(lb BoundType, lower, upper uint64, ub BoundType) Interval {
+		if lb != UnboundBound {
+			if lower > math.MaxUint64-d {
+				lower = math.MaxUint64
+			} else {
+				lower += d
+			}
+		}
+		if ub != UnboundBound {
+			if upper < d {
+				upper = 0
+			} else {
+				upper -= d
+			}
+		}
+		if lb != UnboundBound && ub != UnboundBound && lower > upper {
+			return Empty()
+		}
+		return NewInterval(lb, lower, upper, ub)
+	})
+}
+
+// CloseGaps sets z to x with every gap between intervals that is no longer
+// than maxGap filled in, and returns z. In discrete mode a gap's length is
+// the number of values missing from it.
+func (z *IntervalSet) CloseGaps(x *IntervalSet, maxGap uint64) *IntervalSet {
+	if x == nil {
+		z.Clear()
+		return z
+	}
+	fill := NewIntervalSet()
+	// Only gaps between two intervals are bounded before normalization
+	for it := x.gapIterator(Unbounded(), false); it.Next(); {
+		lb, lower, upper, ub := it.Interval().Bounds()
+		if length, ok := span(x.discrete, lb, lower, upper, ub); ok && length <= maxGap {
+			fill.Add(fill, it.Interval())
+		}
+	}
+	return z.Union(x, fill)
+}
+
+// DropShorterThan sets z to x without any bounded interval shorter than
+// minLen, and returns z. In discrete mode an interval's length is the number
+// of values it holds.
+func (z *IntervalSet) DropShorterThan(x *IntervalSet, minLen uint64) *IntervalSet {
+	if x == nil {
+		z.Clear()
+		return z
+	}
+	drop := NewIntervalSet()
+	x.eachInterval(func(lb BoundType, lower, upper uint64, ub BoundType) bool {
+		if length, ok := span(x.discrete, lb, lower, upper, ub); ok && length < minLen {
+			drop.Add(drop, NewInterval(lb, lower, upper, ub))
+		}
+		return true
+	})
+	return z.Difference(x, drop)
+}
+
+// MinkowskiSum sets z to every sum of a member of x and a member of y, as
+// computed by Interval.Add, and returns z.
+func (z *IntervalSet) MinkowskiSum(x, y *IntervalSet) *IntervalSet {
+	return z.pairwise(x, y, Interval.Add)
+}
+
+// MinkowskiDifference sets z to every difference of a member of x and a
+// member of y, as computed by Interval.Sub, and returns z.
+func (z *IntervalSet) MinkowskiDifference(x, y *IntervalSet) *IntervalSet {
+	return z.pairwise(x, y, Interval.Sub)
+}
+
+// pairwise sets z to the union of f applied to every pair of intervals from
+// x and y, and returns z.
+func (z *IntervalSet) pairwise(x, y *IntervalSet, f func(a, b Interval) Interval) *IntervalSet {
+	if x == nil || y == nil {
+		z.Clear()
+		return z
+	}
+	out := Tree{nodes: make([]node, 1, len(x.nodes)+len(y.nodes))}
+	x.eachInterval(func(alb BoundType, alower, aupper uint64, aub BoundType) bool {
+		a := NewInterval(alb, alower, aupper, aub)
+		y.eachInterval(func(blb BoundType, blower, bupper uint64, bub BoundType) bool {
+			ival := f(a, NewInterval(blb, blower, bupper, bub))
+			out.mergeRoot(&out, &ival.Tree, out.root, ival.root, out.ul, ival.ul, or)
+			return true
+		})
+		return true
+	})
+	out.discrete = z.discrete
+	z.Tree = out
+	return z.normalize()
+}
+
+// Chunk returns an iterator over the intervals of z, with any spanning more
+// than maxLen points cut into pieces of maxLen, counted from the start of
+// each interval. A maxLen of 0 leaves intervals whole.
+func (z *IntervalSet) Chunk(maxLen uint64) *ChunkIterator {
+	return NewChunkIterator(z.Tree, maxLen, false, z.discrete)
+}
+
+// ChunkAligned is like Chunk, but cuts at multiples of maxLen.
+func (z *IntervalSet) ChunkAligned(maxLen uint64) *ChunkIterator {
+	return NewChunkIterator(z.Tree, maxLen, true, z.discrete)
+}
+
+// LargestGap returns the longest part of bounds that z doesn't cover, the
+// lowest one if several tie, or an empty interval if z covers all of bounds.
+func (z *IntervalSet) LargestGap(bounds Interval) Interval {
+	var (
+		largest = Empty()
+		size    uint64
+	)
+	for it := z.GapIterator(bounds); it.Next(); {
+		ival := it.Interval().detach()
+		lb, lower, upper, ub := ival.Bounds()
+		if lb == UnboundBound || ub == UnboundBound {
+			// Nothing can be longer, and ties go to the lowest
+			return ival
+		}
+		if largest.IsEmpty() || upper-lower > size {
+			largest, size = ival, upper-lower
+		}
+	}
+	return largest
+}
+
+// ReverseIterator returns an iterator over the intervals of z in descending
+// order.
+func (z *IntervalSet) ReverseIterator() *IntervalIterator {
+	return NewReverseIntervalIterator(z.Tree)
+}
+
+func (z *IntervalSet) PointIterator(budget uint64) *PointIterator {
+	return NewPointIterator(z.Tree, budget)
+}
+
+func (z *IntervalSet) ReversePointIterator(budget uint64) *PointIterator {
+	return NewReversePointIterator(z.Tree, budget)
+}
+
 func (z *IntervalSet) String() string {
 	if z.root == 0 {
 		if z.ul {
@@ -71,8 +465,9 @@ func (z *IntervalSet) Copy(x *IntervalSet) *IntervalSet {
 		z.numfree = x.numfree
 		z.ul = x.ul
 		z.nodes = append(z.nodes[:0:0], x.nodes...)
+		z.hashes = nil
 	}
-	return z
+	return z.normalize()
 }
 
 func (z *IntervalSet) Add(x *IntervalSet, ival ...Interval) *IntervalSet {
@@ -94,7 +489,7 @@ func (z *IntervalSet) Complement(x *IntervalSet) *IntervalSet {
 		z.Copy(x)
 	}
 	z.ul = !z.ul
-	return z
+	return z.normalize()
 }
 
 func (z *IntervalSet) Cardinality() int {
@@ -104,13 +499,99 @@ func (z *IntervalSet) Cardinality() int {
 		}
 		return 0
 	}
-	i := (&z.nodes[z.root]).count / 2
-	if z.ul || i == 0 {
+	i := z.starts(z.root, z.ul)
+	if z.ul {
 		i += 1
 	}
 	return int(i)
 }
 
+// Measure returns the number of uint64 values in z. The whole domain holds
+// 2^64 of them, which is why the result doesn't fit in a uint64.
+func (z *IntervalSet) Measure() *big.Int {
+	var hi, lo, carry uint64
+	z.eachInterval(func(lb BoundType, lower, upper uint64, ub BoundType) bool {
+		if l, u, ok := integerRange(lb, lower, upper, ub); ok {
+			lo, carry = bits.Add64(lo, u-l, 0)
+			hi += carry
+			lo, carry = bits.Add64(lo, 1, 0)
+			hi += carry
+		}
+		return true
+	})
+	return uint128(hi, lo)
+}
+
+// Length returns the total distance between the bounds of each interval in
+// z, treating the domain as continuous, or false if z is unbounded.
+func (z *IntervalSet) Length() (*big.Int, bool) {
+	var (
+		hi, lo, carry uint64
+		finite        = true
+	)
+	z.eachInterval(func(lb BoundType, lower, upper uint64, ub BoundType) bool {
+		if lb == UnboundBound || ub == UnboundBound {
+			finite = false
+			return false
+		}
+		lo, carry = bits.Add64(lo, upper-lower, 0)
+		hi += carry
+		return true
+	})
+	if !finite {
+		return nil, false
+	}
+	return uint128(hi, lo), true
+}
+
+func uint128(hi, lo uint64) *big.Int {
+	v := new(big.Int).SetUint64(hi)
+	return v.Lsh(v, 64).Or(v, new(big.Int).SetUint64(lo))
+}
+
+// NthInterval returns the interval at index i in ascending order, or an
+// empty interval if there are no more than i intervals.
+func (z *IntervalSet) NthInterval(i int) Interval {
+	if i < 0 {
+		return Empty()
+	}
+	start, before, ok := z.nthStart(uint(i))
+	if !ok {
+		return Empty()
+	}
+	ival, _ := z.intervalFrom(start, before)
+	return ival
+}
+
+// IndexOf returns the number of intervals that start below val, which is
+// also the index of the first interval starting at or above it.
+func (z *IntervalSet) IndexOf(val uint64) int {
+	return int(z.rank(val))
+}
+
+// Intervals returns up to limit intervals in ascending order, starting with
+// the one at index offset.
+func (z *IntervalSet) Intervals(offset, limit int) []Interval {
+	if offset < 0 || limit <= 0 {
+		return nil
+	}
+	if n := z.Cardinality() - offset; n < limit {
+		// The page is sized by what's there, not by what was asked for
+		limit = n
+	}
+	if limit <= 0 {
+		return []Interval{}
+	}
+	start, before, ok := z.nthStart(uint(offset))
+	ivals := make([]Interval, 0, limit)
+	for ok && len(ivals) < limit {
+		ival, end := z.intervalFrom(start, before)
+		ivals = append(ivals, ival)
+		start, before, ok = z.nextStart(start, before, end)
+	}
+	return ivals
+}
+
 func (z *IntervalSet) FirstInterval() Interval {
 	if z.IsEmpty() {
 		return Empty()
@@ -128,6 +609,17 @@ func (z *IntervalSet) FirstInterval() Interval {
 	return ival
 }
 
+// LastInterval returns the greatest interval of z.
+func (z *IntervalSet) LastInterval() Interval {
+	n := z.Cardinality()
+	if n == 0 {
+		return Empty()
+	}
+	start, before, _ := z.nthStart(uint(n - 1))
+	ival, _ := z.intervalFrom(start, before)
+	return ival
+}
+
 func (z *IntervalSet) Extent() Interval {
 	if z.IsEmpty() {
 		return Empty()
@@ -257,3 +749,85 @@ func (z *IntervalSet) IntervalContaining(val uint64) (ival Interval) {
 	ival = ival.Intersection(rival)
 	return
 }
+
+// Ceiling returns the least member of z that is at or above val, and false
+// if there is none.
+func (z *IntervalSet) Ceiling(val uint64) (uint64, bool) {
+	i := z.rank(val)
+	if i > 0 {
+		// The interval starting below val may still reach it
+		i -= 1
+	}
+	start, before, ok := z.nthStart(i)
+	for ok {
+		ival, end := z.intervalFrom(start, before)
+		if lo, hi, ok := ival.integers(); ok && hi >= val {
+			if lo < val {
+				return val, true
+			}
+			return lo, true
+		}
+		start, before, ok = z.nextStart(start, before, end)
+	}
+	return 0, false
+}
+
+// Floor returns the greatest member of z that is at or below val, and false
+// if there is none.
+func (z *IntervalSet) Floor(val uint64) (floor uint64, ok bool) {
+	z.eachIntervalDown(val, func(lb BoundType, lower, upper uint64, ub BoundType) bool {
+		lo, hi, has := NewInterval(lb, lower, upper, ub).integers()
+		if !has || lo > val {
+			return true
+		}
+		if hi > val {
+			hi = val
+		}
+		floor, ok = hi, true
+		return false
+	})
+	return
+}
+
+// NextInterval returns the first interval of z whose members all lie above
+// val, skipping any that hold no uint64 values.
+func (z *IntervalSet) NextInterval(val uint64) (Interval, bool) {
+	start, before, ok := z.nthStart(z.rank(val))
+	for ok {
+		ival, end := z.intervalFrom(start, before)
+		if lo, _, ok := ival.integers(); ok && lo > val {
+			return ival, true
+		}
+		start, before, ok = z.nextStart(start, before, end)
+	}
+	return Empty(), false
+}
+
+// PrevInterval returns the last interval of z whose members all lie below
+// val, skipping any that hold no uint64 values.
+func (z *IntervalSet) PrevInterval(val uint64) (prev Interval, ok bool) {
+	prev = Empty()
+	z.eachIntervalDown(val, func(lb BoundType, lower, upper uint64, ub BoundType) bool {
+		ival := NewInterval(lb, lower, upper, ub)
+		if _, hi, has := ival.integers(); has && hi < val {
+			prev, ok = ival, true
+			return false
+		}
+		return true
+	})
+	return
+}
+
+// Nearest returns the member of z closest to val, preferring the lower one
+// when two are equally close, and false if z has no members.
+func (z *IntervalSet) Nearest(val uint64) (uint64, bool) {
+	f, fok := z.Floor(val)
+	c, cok := z.Ceiling(val)
+	switch {
+	case !fok:
+		return c, cok
+	case !cok, val-f <= c-val:
+		return f, true
+	}
+	return c, true
+}
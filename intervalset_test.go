@@ -2,6 +2,7 @@ package bandit_test
 
 import (
 	"fmt"
+	"math"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
@@ -127,6 +128,13 @@ var _ = Describe("Set", func() {
 		}
 	})
 
+	It("should intersect a bound with a tree that contains it on the right", func() {
+		x := NewIntervalSet(AtOrBelow(204))
+		y := NewIntervalSet(Below(181), Closed(191, 204))
+		Ω(NewIntervalSet().Intersection(x, y).Equals(y)).Should(BeTrue())
+		Ω(NewIntervalSet().Intersection(y, x).Equals(y)).Should(BeTrue())
+	})
+
 	It("should intersect with (0, inf) correctly", func() {
 		ivals := []Interval{
 			MustParseIntervalString("[1581228000, 1581400800)"),
@@ -154,3 +162,438 @@ var _ = Describe("Set", func() {
 		Ω(c.Equals(a)).Should(BeTrue())
 	})
 })
+
+var _ = Describe("Set order statistics", func() {
+	var (
+		ivals = []Interval{
+			Below(2),
+			LeftOpen(2, 4),
+			Closed(5, 10),
+			Point(12),
+			Open(15, 17),
+			Above(17),
+		}
+		a = NewIntervalSet(ivals...)
+	)
+
+	It("should count intervals exactly", func() {
+		Ω(a.Cardinality()).Should(Equal(len(ivals)))
+		b := NewIntervalSet(Closed(1, 10)).Difference(NewIntervalSet(Closed(1, 10)), NewIntervalSet(Point(4), Point(6)))
+		Ω(b.Cardinality()).Should(Equal(3))
+	})
+
+	It("should select the nth interval", func() {
+		for i, ival := range ivals {
+			actual := a.NthInterval(i)
+			Ω(actual.Equals(ival)).Should(BeTrue(), "%s != %s", actual, ival)
+		}
+		Ω(a.NthInterval(len(ivals)).IsEmpty()).Should(BeTrue())
+		Ω(a.NthInterval(-1).IsEmpty()).Should(BeTrue())
+	})
+
+	It("should find the last interval", func() {
+		Ω(a.LastInterval().Equals(Above(17))).Should(BeTrue())
+		b := NewIntervalSet(Closed(1, 10)).Difference(NewIntervalSet(Closed(1, 10)), NewIntervalSet(Point(10)))
+		Ω(b.LastInterval().Equals(RightOpen(1, 10))).Should(BeTrue())
+		Ω(NewIntervalSet().LastInterval().IsEmpty()).Should(BeTrue())
+		Ω(NewIntervalSet(Unbounded()).LastInterval().Equals(Unbounded())).Should(BeTrue())
+	})
+
+	It("should rank values", func() {
+		expected := map[uint64]int{0: 1, 2: 1, 3: 2, 5: 2, 6: 3, 12: 3, 13: 4, 17: 5, 18: 6}
+		for val, idx := range expected {
+			Ω(a.IndexOf(val)).Should(Equal(idx), "IndexOf(%d)", val)
+		}
+	})
+
+	It("should page through intervals", func() {
+		page := a.Intervals(2, 3)
+		Ω(page).Should(HaveLen(3))
+		for i, ival := range page {
+			Ω(ival.Equals(ivals[i+2])).Should(BeTrue(), "%s != %s", ival, ivals[i+2])
+		}
+		Ω(a.Intervals(5, 3)).Should(HaveLen(1))
+		Ω(a.Intervals(6, 3)).Should(BeEmpty())
+	})
+
+	It("should not size pages by the limit alone", func() {
+		b := NewIntervalSet(Closed(1, 2), Closed(5, 6))
+		Ω(b.Intervals(0, math.MaxInt64)).Should(HaveLen(2))
+		Ω(b.Intervals(1, 1<<28)).Should(HaveLen(1))
+		Ω(b.Intervals(math.MaxInt64, math.MaxInt64)).Should(BeEmpty())
+	})
+
+	It("should agree with iteration over large sets", func() {
+		b := NewIntervalSet()
+		for i := uint64(0); i < 1000; i++ {
+			b.Add(b, Closed(i*10, i*10+5))
+		}
+		Ω(b.Cardinality()).Should(Equal(1000))
+		Ω(b.NthInterval(617).Equals(Closed(6170, 6175))).Should(BeTrue())
+		Ω(b.IndexOf(6171)).Should(Equal(618))
+		for i, ival := range b.Intervals(990, 20) {
+			Ω(ival.Equals(Closed(uint64(990+i)*10, uint64(990+i)*10+5))).Should(BeTrue())
+		}
+	})
+})
+
+var _ = Describe("Set neighbours", func() {
+	var a = NewIntervalSet(Open(2, 5), Point(8), Open(10, 11), LeftOpen(20, 30), Above(40))
+
+	DescribeTable("ceiling",
+		func(val uint64, expected uint64, ok bool) {
+			actual, aok := a.Ceiling(val)
+			Ω(aok).Should(Equal(ok))
+			Ω(actual).Should(Equal(expected))
+		},
+		Entry("below everything", uint64(0), uint64(3), true),
+		Entry("open lower bound", uint64(2), uint64(3), true),
+		Entry("inside", uint64(4), uint64(4), true),
+		Entry("open upper bound", uint64(5), uint64(8), true),
+		Entry("point", uint64(8), uint64(8), true),
+		Entry("skips intervals with no integers", uint64(9), uint64(21), true),
+		Entry("closed upper bound", uint64(30), uint64(30), true),
+		Entry("unbounded", uint64(35), uint64(41), true),
+		Entry("domain edge", uint64(math.MaxUint64), uint64(math.MaxUint64), true),
+	)
+
+	DescribeTable("floor",
+		func(val uint64, expected uint64, ok bool) {
+			actual, aok := a.Floor(val)
+			Ω(aok).Should(Equal(ok))
+			Ω(actual).Should(Equal(expected))
+		},
+		Entry("below everything", uint64(2), uint64(0), false),
+		Entry("inside", uint64(4), uint64(4), true),
+		Entry("open upper bound", uint64(5), uint64(4), true),
+		Entry("point", uint64(9), uint64(8), true),
+		Entry("skips intervals with no integers", uint64(20), uint64(8), true),
+		Entry("closed upper bound", uint64(35), uint64(30), true),
+		Entry("open lower bound", uint64(40), uint64(30), true),
+		Entry("domain edge", uint64(math.MaxUint64), uint64(math.MaxUint64), true),
+	)
+
+	It("should find neighbouring intervals", func() {
+		next, ok := a.NextInterval(3)
+		Ω(ok).Should(BeTrue())
+		Ω(next.Equals(Point(8))).Should(BeTrue(), "%s", next)
+		next, ok = a.NextInterval(8)
+		Ω(ok).Should(BeTrue())
+		Ω(next.Equals(LeftOpen(20, 30))).Should(BeTrue(), "%s", next)
+		next, ok = a.NextInterval(40)
+		Ω(ok).Should(BeTrue())
+		Ω(next.Equals(Above(40))).Should(BeTrue(), "%s", next)
+		next, ok = a.NextInterval(41)
+		Ω(ok).Should(BeFalse())
+		Ω(next.IsEmpty()).Should(BeTrue())
+
+		prev, ok := a.PrevInterval(25)
+		Ω(ok).Should(BeTrue())
+		Ω(prev.Equals(Point(8))).Should(BeTrue(), "%s", prev)
+		prev, ok = a.PrevInterval(5)
+		Ω(ok).Should(BeTrue())
+		Ω(prev.Equals(Open(2, 5))).Should(BeTrue(), "%s", prev)
+		_, ok = a.PrevInterval(3)
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("should step back over runs of intervals with no integers", func() {
+		b := NewIntervalSet(Point(0), Open(1, 2), Open(2, 3), Open(3, 4), Open(4, 5))
+		floor, ok := b.Floor(4)
+		Ω(ok).Should(BeTrue())
+		Ω(floor).Should(Equal(uint64(0)))
+		prev, ok := b.PrevInterval(5)
+		Ω(ok).Should(BeTrue())
+		Ω(prev.Equals(Point(0))).Should(BeTrue(), "%s", prev)
+		_, ok = b.PrevInterval(0)
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("should find the nearest member", func() {
+		for val, expected := range map[uint64]uint64{0: 3, 4: 4, 6: 4, 7: 8, 14: 8, 15: 21, 35: 30, 36: 41} {
+			actual, ok := a.Nearest(val)
+			Ω(ok).Should(BeTrue())
+			Ω(actual).Should(Equal(expected), "Nearest(%d)", val)
+		}
+		_, ok := NewIntervalSet(Below(0)).Nearest(5)
+		Ω(ok).Should(BeFalse())
+	})
+})
+
+var _ = Describe("Set gaps", func() {
+	var (
+		a = NewIntervalSet(Closed(10, 20), Open(30, 40), Point(45), AtOrAbove(60))
+	)
+
+	It("should find the gaps within bounds", func() {
+		gaps := a.Gaps(Closed(0, 100))
+		expected := []Interval{RightOpen(0, 10), LeftOpen(20, 30), RightOpen(40, 45), Open(45, 60)}
+		Ω(gaps).Should(HaveLen(len(expected)))
+		for i, gap := range gaps {
+			Ω(gap.Equals(expected[i])).Should(BeTrue(), "%s != %s", gap, expected[i])
+		}
+		gaps = a.Gaps(Unbounded())
+		Ω(gaps[0].Equals(Below(10))).Should(BeTrue())
+		Ω(a.Gaps(Open(12, 18))).Should(BeEmpty())
+		Ω(a.Gaps(Empty())).Should(BeEmpty())
+		Ω(NewIntervalSet().Gaps(Open(1, 2))[0].Equals(Open(1, 2))).Should(BeTrue())
+	})
+
+	It("should step through gaps with an iterator", func() {
+		it := a.GapIterator(Open(15, 50))
+		Ω(it.Next()).Should(BeTrue())
+		Ω(it.Interval().Equals(LeftOpen(20, 30))).Should(BeTrue())
+		Ω(it.Next()).Should(BeTrue())
+		Ω(it.Next()).Should(BeTrue())
+		Ω(it.Interval().Equals(Open(45, 50))).Should(BeTrue())
+		Ω(it.Next()).Should(BeFalse())
+	})
+
+	It("should find the largest gap", func() {
+		Ω(a.LargestGap(Closed(0, 100)).Equals(Open(45, 60))).Should(BeTrue())
+		Ω(a.LargestGap(Closed(0, 35)).Equals(RightOpen(0, 10))).Should(BeTrue())
+		Ω(a.LargestGap(Unbounded()).Equals(Below(10))).Should(BeTrue())
+		Ω(a.LargestGap(Closed(60, 100)).IsEmpty()).Should(BeTrue())
+	})
+
+	It("should only report gaps holding integers in discrete mode", func() {
+		b := NewIntervalSet().SetDiscrete(true).Add(nil, Closed(1, 3), Closed(5, 7))
+		gaps := b.Gaps(Closed(0, 10))
+		expected := []Interval{Point(0), Point(4), Closed(8, 10)}
+		Ω(gaps).Should(HaveLen(len(expected)))
+		for i, gap := range gaps {
+			Ω(gap.Equals(expected[i])).Should(BeTrue(), "%s != %s", gap, expected[i])
+		}
+		Ω(b.Gaps(Open(3, 4))).Should(BeEmpty())
+	})
+
+	It("should handle gaps on both sides of the top bit", func() {
+		b := NewIntervalSet(Closed(1<<63-10, 1<<63+10))
+		gaps := b.Gaps(Unbounded())
+		Ω(gaps).Should(HaveLen(2))
+		Ω(gaps[1].Equals(Above(1<<63 + 10))).Should(BeTrue())
+		Ω(b.Gaps(Closed(1<<63, 1<<63+20))[0].Equals(LeftOpen(1<<63+10, 1<<63+20))).Should(BeTrue())
+	})
+})
+
+var _ = Describe("Set splitting", func() {
+	var (
+		a = NewIntervalSet(Closed(10, 20), Open(30, 40), Point(45), AtOrAbove(60))
+	)
+
+	It("should split around a value", func() {
+		below, above := a.Split(35)
+		Ω(below.Equals(NewIntervalSet(Closed(10, 20), Open(30, 35)))).Should(BeTrue())
+		Ω(above.Equals(NewIntervalSet(RightOpen(35, 40), Point(45), AtOrAbove(60)))).Should(BeTrue())
+		below, above = a.Split(0)
+		Ω(below.IsEmpty()).Should(BeTrue())
+		Ω(above.Equals(a)).Should(BeTrue())
+	})
+
+	It("should keep discrete mode", func() {
+		b := NewIntervalSet(Closed(1, 9)).SetDiscrete(true)
+		below, above := b.Split(5)
+		Ω(below.IsDiscrete()).Should(BeTrue())
+		Ω(below.Equals(NewIntervalSet(Closed(1, 4)).SetDiscrete(true))).Should(BeTrue())
+		Ω(above.Equals(NewIntervalSet(Closed(5, 9)).SetDiscrete(true))).Should(BeTrue())
+	})
+})
+
+var _ = Describe("Set arithmetic", func() {
+	It("should add every pair of intervals", func() {
+		departures := NewIntervalSet(Closed(100, 110), Closed(200, 210))
+		travel := NewIntervalSet(Closed(30, 45), RightOpen(90, 95))
+		actual := NewIntervalSet().MinkowskiSum(departures, travel)
+		expected := NewIntervalSet(Closed(130, 155), RightOpen(190, 205), Closed(230, 255), RightOpen(290, 305))
+		Ω(actual.Equals(expected)).Should(BeTrue(), "%s != %s", actual, expected)
+	})
+
+	It("should merge overlapping sums", func() {
+		x := NewIntervalSet(Closed(0, 10), Open(12, 20))
+		actual := NewIntervalSet().MinkowskiSum(x, NewIntervalSet(Closed(0, 2)))
+		Ω(actual.Equals(NewIntervalSet(RightOpen(0, 22)))).Should(BeTrue(), "%s", actual)
+	})
+
+	It("should subtract every pair of intervals", func() {
+		x := NewIntervalSet(Closed(100, 110))
+		y := NewIntervalSet(Closed(5, 10), Closed(200, 300))
+		actual := NewIntervalSet().MinkowskiDifference(x, y)
+		Ω(actual.Equals(NewIntervalSet(Point(0), Closed(90, 105)))).Should(BeTrue(), "%s", actual)
+	})
+
+	It("should be empty when either set is", func() {
+		x := NewIntervalSet(Closed(1, 2))
+		Ω(NewIntervalSet().MinkowskiSum(x, NewIntervalSet()).IsEmpty()).Should(BeTrue())
+		Ω(NewIntervalSet().MinkowskiDifference(NewIntervalSet(), x).IsEmpty()).Should(BeTrue())
+	})
+})
+
+var _ = Describe("Set morphology", func() {
+	var (
+		a = NewIntervalSet(Closed(10, 20), Open(25, 30), Point(40))
+		// A hole at 5
+		h = NewIntervalSet(Below(5), Above(5))
+	)
+
+	DescribeTable("dilating and eroding", func(op string, x *IntervalSet, d int, expected *IntervalSet) {
+		apply := func(z, x *IntervalSet) *IntervalSet {
+			if op == "dilate" {
+				return z.Dilate(x, uint64(d))
+			}
+			return z.Erode(x, uint64(d))
+		}
+		actual := apply(NewIntervalSet(), x)
+		Ω(actual.Equals(expected)).Should(BeTrue(), "%s != %s", actual, expected)
+		actual = NewIntervalSet().Copy(x)
+		apply(actual, actual)
+		Ω(actual.Equals(expected)).Should(BeTrue(), "in place: %s != %s", actual, expected)
+	},
+		Entry("dilate merging intervals", "dilate", a, 3, NewIntervalSet(RightOpen(7, 33), Closed(37, 43))),
+		Entry("dilate by nothing", "dilate", a, 0, a),
+		Entry("dilate at 0", "dilate", NewIntervalSet(Open(2, 5)), 5, NewIntervalSet(RightOpen(0, 10))),
+		Entry("dilate at max", "dilate", NewIntervalSet(Closed(math.MaxUint64-2, math.MaxUint64-1)), 5, NewIntervalSet(Closed(math.MaxUint64-7, math.MaxUint64))),
+		Entry("dilate unbounded", "dilate", NewIntervalSet(Below(5)), 3, NewIntervalSet(Below(8))),
+		Entry("dilate over a hole", "dilate", h, 1, NewIntervalSet(Unbounded())),
+		Entry("dilate a hole by nothing", "dilate", h, 0, h),
+		Entry("dilate into touching intervals", "dilate", NewIntervalSet(Closed(0, 2), Closed(4, 6)), 1, NewIntervalSet(Closed(0, 7))),
+		Entry("erode", "erode", a, 3, NewIntervalSet(Closed(13, 17))),
+		Entry("erode by nothing", "erode", a, 0, a),
+		Entry("erode at 0", "erode", NewIntervalSet(Closed(0, 5)), 1, NewIntervalSet(Closed(1, 4))),
+		Entry("erode unbounded", "erode", NewIntervalSet(Below(5), AtOrAbove(math.MaxUint64-1)), 3, NewIntervalSet(Below(2), AtOrAbove(math.MaxUint64))),
+		Entry("erode around a hole", "erode", h, 1, NewIntervalSet(Below(4), Above(6))),
+	)
+
+	It("should dilate in place over nodes it has freed", func() {
+		x := NewIntervalSet(Unbounded())
+		x.Difference(x, NewIntervalSet(Closed(47, 53)))
+		Ω(x.Dilate(x, 5).IsUnbounded()).Should(BeTrue(), "%s", x)
+	})
+
+	It("should close short gaps", func() {
+		outages := NewIntervalSet(RightOpen(100, 130), RightOpen(150, 200), RightOpen(260, 300))
+		actual := NewIntervalSet().CloseGaps(outages, 30)
+		Ω(actual.Equals(NewIntervalSet(RightOpen(100, 200), RightOpen(260, 300)))).Should(BeTrue(), "%s", actual)
+		Ω(NewIntervalSet().CloseGaps(h, 0).IsUnbounded()).Should(BeTrue())
+	})
+
+	It("should leave unbounded gaps open", func() {
+		b := NewIntervalSet(Closed(1, 3), Closed(5, 7)).SetDiscrete(true)
+		actual := NewIntervalSet().SetDiscrete(true).CloseGaps(b, 10)
+		Ω(actual.Equals(NewIntervalSet(Closed(1, 7)).SetDiscrete(true))).Should(BeTrue(), "%s", actual)
+	})
+
+	It("should drop short intervals", func() {
+		b := NewIntervalSet(RightOpen(0, 3), RightOpen(10, 20), Point(30), AtOrAbove(40))
+		b.DropShorterThan(b, 5)
+		Ω(b.Equals(NewIntervalSet(RightOpen(10, 20), AtOrAbove(40)))).Should(BeTrue(), "%s", b)
+		c := NewIntervalSet(Closed(1, 4), Closed(10, 11)).SetDiscrete(true)
+		Ω(c.DropShorterThan(c, 4).Equals(NewIntervalSet(Closed(1, 4)).SetDiscrete(true))).Should(BeTrue())
+	})
+})
+
+var _ = Describe("Set measure", func() {
+
+	DescribeTable("counting members",
+		func(expected string, ivals ...Interval) {
+			Ω(NewIntervalSet(ivals...).Measure().String()).Should(Equal(expected))
+		},
+		Entry("empty", "0"),
+		Entry("closed", "11", Closed(0, 10)),
+		Entry("open", "9", Open(0, 10)),
+		Entry("no integers", "0", Open(1, 2)),
+		Entry("points", "2", Point(3), Point(5)),
+		Entry("below zero", "0", Below(0)),
+		Entry("above the top", "0", Above(math.MaxUint64)),
+		Entry("upper unbounded", "18446744073709551606", Above(9)),
+		Entry("lower unbounded", "10", Below(10)),
+		Entry("whole domain", "18446744073709551616", Unbounded()),
+		Entry("whole domain in pieces", "18446744073709551616", AtOrBelow(10), Above(10)),
+	)
+
+	It("should count around holes", func() {
+		a := NewIntervalSet(Closed(0, 10), Above(20))
+		a.Difference(a, NewIntervalSet(Point(5), Point(30)))
+		Ω(a.Measure().String()).Should(Equal("18446744073709551604"))
+	})
+
+	It("should report the continuous length", func() {
+		length, ok := NewIntervalSet(Open(0, 10), Closed(20, 25), Point(30)).Length()
+		Ω(ok).Should(BeTrue())
+		Ω(length.Int64()).Should(BeNumerically("==", 15))
+		_, ok = NewIntervalSet(Open(0, 10), Above(20)).Length()
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("should measure each key of a map", func() {
+		m := NewIntervalMap().Add(nil, "a", Closed(0, 9))
+		m.Add(m, "b", RightOpen(0, 100), Point(200))
+		measures := m.MeasureByKey()
+		Ω(measures).Should(HaveLen(2))
+		Ω(measures["a"].Int64()).Should(BeNumerically("==", 10))
+		Ω(measures["b"].Int64()).Should(BeNumerically("==", 101))
+	})
+})
+
+var _ = Describe("Discrete sets", func() {
+
+	discrete := func(ivals ...Interval) *IntervalSet {
+		return NewIntervalSet(ivals...).SetDiscrete(true)
+	}
+
+	It("should treat equivalent integer intervals as equal", func() {
+		Ω(discrete(Open(1, 5)).Equals(discrete(Closed(2, 4)))).Should(BeTrue())
+		Ω(discrete(LeftOpen(1, 5)).Equals(discrete(RightOpen(2, 6)))).Should(BeTrue())
+		Ω(NewIntervalSet(Open(1, 5)).Equals(NewIntervalSet(Closed(2, 4)))).Should(BeFalse())
+		Ω(Open(1, 5).Normalize().Equals(Closed(2, 4))).Should(BeTrue())
+		Ω(Open(1, 2).Normalize().IsEmpty()).Should(BeTrue())
+	})
+
+	It("should coalesce adjacent intervals", func() {
+		a := discrete(Closed(1, 3), Closed(4, 6))
+		Ω(a.String()).Should(Equal("[1, 6]"))
+		Ω(a.Cardinality()).Should(Equal(1))
+		a.Add(a, Open(10, 12), RightOpen(7, 8))
+		Ω(a.String()).Should(Equal("[1, 7], [11]"))
+	})
+
+	It("should handle the edges of the domain", func() {
+		Ω(discrete(Above(math.MaxUint64)).IsEmpty()).Should(BeTrue())
+		Ω(discrete(Below(0)).IsEmpty()).Should(BeTrue())
+		Ω(discrete(Above(10)).String()).Should(Equal(fmt.Sprintf("[11, %d]", uint64(math.MaxUint64))))
+		Ω(discrete(Unbounded()).Equals(discrete(Closed(0, math.MaxUint64)))).Should(BeTrue())
+	})
+
+	It("should stay canonical through operations", func() {
+		a := discrete(Closed(0, 10))
+		b := discrete(Open(2, 4))
+		Ω(NewIntervalSet().SetDiscrete(true).Difference(a, b).String()).Should(Equal("[0, 2], [4, 10]"))
+		a.Complement(a)
+		Ω(a.String()).Should(Equal(fmt.Sprintf("[11, %d]", uint64(math.MaxUint64))))
+		a.Complement(a)
+		Ω(a.Equals(discrete(Closed(0, 10)))).Should(BeTrue())
+		a.Union(a, discrete(Point(11)))
+		Ω(a.String()).Should(Equal("[0, 11]"))
+		Ω(CopySet(a).IsDiscrete()).Should(BeTrue())
+	})
+
+	It("should stay canonical when a plain set flips part of it", func() {
+		a := discrete(Closed(1, 3), Closed(6, 8), Closed(20, 30))
+		a.SymmetricDifference(a, NewIntervalSet(Open(0, 10)))
+		Ω(a.String()).Should(Equal("[4, 5], [9], [20, 30]"))
+		a.Union(a, NewIntervalSet(Open(5, 9), LeftOpen(9, 19)))
+		Ω(a.String()).Should(Equal("[4, 30]"))
+		a.Difference(a, NewIntervalSet(Open(10, 12), Above(29)))
+		Ω(a.String()).Should(Equal("[4, 10], [12, 29]"))
+	})
+
+	It("should apply to every set in a discrete map", func() {
+		m := NewIntervalMap().SetDiscrete(true)
+		m.Add(m, "a", Closed(1, 3), Closed(4, 6))
+		m.AddSet(m, "b", NewIntervalSet(Open(0, 1)))
+		Ω(m.Get("a").String()).Should(Equal("[1, 6]"))
+		Ω(m.ValueSlice()).Should(ConsistOf("a"))
+		other := NewIntervalMap().Add(nil, "a", Open(0, 7))
+		Ω(m.Equals(CopyMap(other).SetDiscrete(true))).Should(BeTrue())
+		Ω(CopyMap(m).IsDiscrete()).Should(BeTrue())
+	})
+})
@@ -1,5 +1,7 @@
 package bandit
 
+import "math"
+
 type (
 	IntervalIterator struct {
 		t         Tree
@@ -8,6 +10,36 @@ type (
 		ival      Interval
 		done      bool
 		holeleft  bool
+		bounded   bool
+		bounds    Interval
+		reverse   bool
+		discrete  bool
+	}
+	// PointIterator visits each uint64 member of a set, stopping once it has
+	// used up its budget so unbounded sets can't run forever.
+	PointIterator struct {
+		t       Tree
+		budget  uint64
+		reverse bool
+		start   uint
+		before  bool
+		more    bool
+		index   int
+		pending bool
+		lo, hi  uint64
+		point   uint64
+	}
+	// ChunkIterator visits the intervals of a set, cutting any longer than
+	// maxLen into consecutive pieces.
+	ChunkIterator struct {
+		it           *IntervalIterator
+		maxLen       uint64
+		aligned      bool
+		discrete     bool
+		pending      bool
+		lb, ub       BoundType
+		lower, upper uint64
+		ival         Interval
 	}
 	MapIterator struct {
 		m         *IntervalMap
@@ -38,23 +70,201 @@ func (mi *MapIterator) Next() bool {
 
 func NewIntervalIterator(t Tree) *IntervalIterator {
 	numnodes := len(t.nodes) - int(t.numfree)
-	nodestack := append(make([]uint, 0, numnodes), t.root)
-	ulstack := append(make([]bool, 0, numnodes), t.ul)
-	return &IntervalIterator{
+	it := &IntervalIterator{
 		t:         t,
-		nodestack: nodestack,
-		ulstack:   ulstack,
+		nodestack: make([]uint, 0, numnodes),
+		ulstack:   make([]bool, 0, numnodes),
 		ival:      Empty(),
 	}
+	it.reset()
+	return it
+}
+
+// NewReverseIntervalIterator returns an iterator over the intervals of t in
+// descending order.
+func NewReverseIntervalIterator(t Tree) *IntervalIterator {
+	it := NewIntervalIterator(t)
+	it.reverse = true
+	it.reset()
+	return it
+}
+
+func (it *IntervalIterator) reset() {
+	ul := it.t.ul
+	if it.reverse {
+		// Walking right to left, we track membership after each node instead
+		ul = ul != it.t.nodes[it.t.root].ul
+	}
+	it.nodestack = append(it.nodestack[:0], it.t.root)
+	it.ulstack = append(it.ulstack[:0], ul)
+	it.done = false
+	it.holeleft = false
+}
+
+// newIntervalIteratorWithin returns an iterator over the intervals of t that
+// overlap bounds, clipped to bounds. If discrete, each is narrowed to the
+// integers it holds, and those with none are skipped.
+func newIntervalIteratorWithin(t Tree, bounds Interval, discrete bool) *IntervalIterator {
+	it := NewIntervalIterator(t)
+	it.bounded, it.bounds, it.discrete = true, bounds, discrete
+	lb, lower, _, _ := bounds.Bounds()
+	switch {
+	case bounds.IsEmpty():
+		it.done = true
+	case lb != UnboundBound:
+		it.Seek(lower)
+	}
+	return it
 }
 
-func (it *IntervalIterator) Interval() (ival Interval) {
+// Seek repositions the iterator so that the next call to Next yields the
+// interval containing x, or the first one after it. A reverse iterator
+// yields the interval containing x, or the last one before it.
+func (it *IntervalIterator) Seek(x uint64) {
+	if it.reverse {
+		it.seekReverse(x)
+		return
+	}
+	t := &it.t
+	i := t.rank(x)
+	if i > 0 {
+		// The interval starting below x may still reach it
+		start, before, _ := t.nthStart(i - 1)
+		if ival, _ := t.intervalFrom(start, before); reaches(ival, x) {
+			i -= 1
+		}
+	}
+	start, before, ok := t.nthStart(i)
+	switch {
+	case !ok:
+		it.nodestack, it.ulstack = it.nodestack[:0], it.ulstack[:0]
+		it.done = true
+		return
+	case start == 0:
+		it.reset()
+		return
+	}
+	// Rebuild the stack as it would stand had we just walked to start: every
+	// right sibling along the path is still pending, deepest on top.
+	it.nodestack, it.ulstack = it.nodestack[:0], it.ulstack[:0]
+	key := t.nodes[start].prefix
+	idx, ul := t.root, t.ul
+	for idx != start {
+		n := &t.nodes[idx]
+		lul := (&t.nodes[n.left]).ul
+		if ZeroAt(key, uint(n.level)) {
+			it.nodestack = append(it.nodestack, n.right)
+			it.ulstack = append(it.ulstack, ul != lul)
+			idx = n.left
+		} else {
+			ul = ul != lul
+			idx = n.right
+		}
+	}
+	it.nodestack = append(it.nodestack, start)
+	it.ulstack = append(it.ulstack, false)
+	it.done = false
+	it.holeleft = before
+}
+
+func (it *IntervalIterator) seekReverse(x uint64) {
+	t := &it.t
+	i := t.rank(x)
+	if start, before, ok := t.nthStart(i); ok {
+		// An interval starting at x still contains it if it's closed there
+		ival, _ := t.intervalFrom(start, before)
+		if lb, lower, _, _ := ival.Bounds(); lb == ClosedBound && lower == x {
+			i += 1
+		}
+	}
+	if i == 0 {
+		it.nodestack, it.ulstack = it.nodestack[:0], it.ulstack[:0]
+		it.done = true
+		return
+	}
+	start, before, _ := t.nthStart(i - 1)
+	_, end := t.intervalFrom(start, before)
+	if end == 0 {
+		it.reset()
+		return
+	}
+	// Rebuild the stack as it would stand had we just walked back to end:
+	// every left sibling along the path is still pending, deepest on top.
+	it.nodestack, it.ulstack = it.nodestack[:0], it.ulstack[:0]
+	key := t.nodes[end].prefix
+	idx := t.root
+	ul := t.ul != t.nodes[idx].ul
+	for idx != end {
+		n := &t.nodes[idx]
+		rul := (&t.nodes[n.right]).ul
+		if ZeroAt(key, uint(n.level)) {
+			ul = ul != rul
+			idx = n.left
+		} else {
+			it.nodestack = append(it.nodestack, n.left)
+			it.ulstack = append(it.ulstack, ul != rul)
+			idx = n.right
+		}
+	}
+	it.nodestack = append(it.nodestack, end)
+	it.ulstack = append(it.ulstack, false)
+	it.done = false
+	// A hole ending the interval also serves as its upper bound
+	it.holeleft = !t.nodes[end].ul && (end != start || before)
+}
+
+// reaches reports whether ival extends as far as x.
+func reaches(ival Interval, x uint64) bool {
+	_, _, upper, ub := ival.Bounds()
+	return ub == UnboundBound || upper > x || (upper == x && ub == ClosedBound)
+}
+
+// Interval returns the current interval. It shares storage with the
+// iterator, so it only holds until the next call to Next.
+func (it *IntervalIterator) Interval() Interval {
 	return it.ival
 }
+
 func (it *IntervalIterator) Next() bool {
+	if !it.next() {
+		return false
+	}
+	if !it.bounded {
+		return true
+	}
+	for {
+		ival := it.ival.detach().Intersection(it.bounds)
+		if it.discrete {
+			ival = ival.Normalize()
+		}
+		if !ival.IsEmpty() {
+			it.ival = ival
+			return true
+		}
+		if beyond(it.ival, it.bounds) {
+			it.done = true
+			return false
+		}
+		if !it.next() {
+			return false
+		}
+	}
+}
+
+// beyond reports whether ival, which doesn't overlap bounds, lies after it.
+func beyond(ival, bounds Interval) bool {
+	lb, lower, _, _ := ival.Bounds()
+	_, _, upper, ub := bounds.Bounds()
+	return lb != UnboundBound && ub != UnboundBound && lower >= upper
+}
+
+func (it *IntervalIterator) next() bool {
 	if it.done {
 		return false
 	}
+	if it.reverse {
+		return it.prev()
+	}
 	var (
 		l         = len(it.nodestack)
 		n         uint
@@ -65,6 +275,11 @@ func (it *IntervalIterator) Next() bool {
 	)
 	if l == 0 || it.nodestack[0] == 0 {
 		it.done = true
+		if l > 0 && it.t.ul {
+			// No boundaries at all, so the set is unbounded
+			it.ival = Unbounded()
+			return true
+		}
 		return false
 	}
 	it.ival.Clear()
@@ -84,31 +299,32 @@ func (it *IntervalIterator) Next() bool {
 		// cur is a leaf
 		if it.holeleft || cur.ul {
 			// This node is part of a single interval
-			idx = it.ival.Tree.takeOwnership(&it.t, n)
 			if !ul {
 				if it.holeleft {
-					(&it.ival.nodes[idx]).incl = false
+					// A hole opens the next interval with an open lower bound
+					idx = it.ival.Tree.node(cur.prefix, 0, 0, 0, true, false)
 					it.holeleft = false
-					ul = !ul
+				} else {
+					idx = it.ival.Tree.takeOwnership(&it.t, n)
 				}
 				// Opening an interval
 				left = idx
-				lul = ul
+				lul = false
 				continue
 			}
 			// Closing an interval
+			idx = it.ival.Tree.takeOwnership(&it.t, n)
 			it.ival.mergeRoot(&it.ival.Tree, &it.ival.Tree, left, idx, lul, ul, and)
 		} else {
 			// This node is either a hole or a point
 			if !ul {
 				// Point
 				it.ival.root = it.ival.Tree.takeOwnership(&it.t, n)
-				// (&it.ival.nodes[it.ival.root]).incl = true // TODO: Make a test for point
 				return true
 			}
-			idx = it.ival.Tree.takeOwnership(&it.t, n)
 			// Hole
-			// Close what we've got
+			// Close what we've got with an open upper bound
+			idx = it.ival.Tree.node(cur.prefix, 0, 0, 0, true, true)
 			it.ival.mergeRoot(&it.ival.Tree, &it.ival.Tree, left, idx, lul, ul, and)
 
 			// Push this node back on the stack, setting a flag so it will be
@@ -128,3 +344,216 @@ func (it *IntervalIterator) Next() bool {
 	}
 	return true
 }
+
+// prev mirrors next, walking leaves from right to left. Upper bounds open
+// intervals and lower bounds close them, and a hole is pushed back to serve
+// as the upper bound of the interval below it.
+func (it *IntervalIterator) prev() bool {
+	var (
+		l          = len(it.nodestack)
+		n          uint
+		ul         bool
+		cur        node
+		idx, right uint
+	)
+	if l == 0 || it.nodestack[0] == 0 {
+		it.done = true
+		if l > 0 && it.t.ul {
+			// No boundaries at all, so the set is unbounded
+			it.ival = Unbounded()
+			return true
+		}
+		return false
+	}
+	it.ival.Clear()
+	for ; l > 0; l = len(it.nodestack) {
+		n, it.nodestack = it.nodestack[l-1], it.nodestack[:l-1]
+		ul, it.ulstack = it.ulstack[l-1], it.ulstack[:l-1]
+		cur = it.t.nodes[n]
+		if cur.level != 0 {
+			// Add the children left first, so we visit right first
+			it.nodestack = append(it.nodestack, cur.left, cur.right)
+			it.ulstack = append(it.ulstack, ul != it.t.nodes[cur.right].ul, ul)
+			continue
+		}
+		// cur is a leaf, and ul is membership just after it
+		if it.holeleft || cur.ul {
+			if !ul {
+				if it.holeleft {
+					// A hole closes the interval below it with an open bound
+					idx = it.ival.Tree.node(cur.prefix, 0, 0, 0, true, true)
+					it.holeleft = false
+				} else {
+					idx = it.ival.Tree.takeOwnership(&it.t, n)
+				}
+				// Opening an interval at its upper bound
+				right = idx
+				continue
+			}
+			// Closing an interval at its lower bound. If right is still 0,
+			// the interval is unbounded above.
+			idx = it.ival.Tree.takeOwnership(&it.t, n)
+			it.ival.mergeRoot(&it.ival.Tree, &it.ival.Tree, idx, right, false, true, and)
+		} else {
+			if !ul {
+				// Point
+				it.ival.root = it.ival.Tree.takeOwnership(&it.t, n)
+				return true
+			}
+			// Hole
+			// Close what we've got with an open lower bound
+			idx = it.ival.Tree.node(cur.prefix, 0, 0, 0, true, false)
+			it.ival.mergeRoot(&it.ival.Tree, &it.ival.Tree, idx, right, false, true, and)
+
+			// Push this node back on the stack, setting a flag so it will be
+			// treated only as the right side of an interval
+			it.holeleft = true
+			it.nodestack = append(it.nodestack, n)
+			it.ulstack = append(it.ulstack, false)
+		}
+		return true
+	}
+	if right > 0 {
+		it.ival.mergeRoot(&it.ival.Tree, &it.ival.Tree, 0, right, true, true, and)
+	}
+	if it.ival.IsEmpty() {
+		it.done = true
+		return false
+	}
+	return true
+}
+
+// NewChunkIterator returns an iterator over the intervals of t, cut into
+// pieces spanning no more than maxLen points. Each cut is open on the piece
+// below and closed on the piece above, so the pieces cover exactly what the
+// original interval did. Cuts fall every maxLen from the start of each
+// interval, or at multiples of maxLen if aligned. If discrete, pieces are
+// narrowed to the integers they hold.
+func NewChunkIterator(t Tree, maxLen uint64, aligned, discrete bool) *ChunkIterator {
+	return &ChunkIterator{
+		it:       NewIntervalIterator(t),
+		maxLen:   maxLen,
+		aligned:  aligned,
+		discrete: discrete,
+		ival:     Empty(),
+	}
+}
+
+func (c *ChunkIterator) Interval() Interval {
+	return c.ival
+}
+
+func (c *ChunkIterator) Next() bool {
+	for {
+		if !c.pending {
+			if !c.it.Next() {
+				return false
+			}
+			c.lb, c.lower, c.upper, c.ub = c.it.Interval().Bounds()
+			c.pending = true
+		}
+		var piece Interval
+		if cut, ok := c.cut(); ok {
+			piece = NewInterval(c.lb, c.lower, cut, OpenBound)
+			c.lb, c.lower = ClosedBound, cut
+		} else {
+			piece = NewInterval(c.lb, c.lower, c.upper, c.ub)
+			c.pending = false
+		}
+		if c.discrete {
+			piece = piece.Normalize()
+		}
+		if !piece.IsEmpty() {
+			c.ival = piece
+			return true
+		}
+	}
+}
+
+// cut returns where the remainder of the current interval should next be
+// cut, and false if it needn't be.
+func (c *ChunkIterator) cut() (uint64, bool) {
+	if c.maxLen == 0 {
+		return 0, false
+	}
+	var base, cut uint64
+	if c.lb != UnboundBound {
+		base = c.lower
+	}
+	switch {
+	case c.aligned:
+		if base/c.maxLen >= math.MaxUint64/c.maxLen {
+			return 0, false
+		}
+		cut = (base/c.maxLen + 1) * c.maxLen
+	default:
+		if base > math.MaxUint64-c.maxLen {
+			return 0, false
+		}
+		cut = base + c.maxLen
+	}
+	return cut, c.ub == UnboundBound || cut < c.upper || (cut == c.upper && c.ub == ClosedBound)
+}
+
+// NewPointIterator returns an iterator over at most budget members of t, in
+// ascending order.
+func NewPointIterator(t Tree, budget uint64) *PointIterator {
+	it := &PointIterator{t: t, budget: budget}
+	it.start, it.before, it.more = it.t.firstStart()
+	return it
+}
+
+// NewReversePointIterator returns an iterator over at most budget members
+// of t, in descending order.
+func NewReversePointIterator(t Tree, budget uint64) *PointIterator {
+	it := &PointIterator{t: t, budget: budget, reverse: true}
+	it.index = (&IntervalSet{Tree: t}).Cardinality() - 1
+	return it
+}
+
+func (it *PointIterator) Point() uint64 {
+	return it.point
+}
+
+func (it *PointIterator) Next() bool {
+	if it.budget == 0 {
+		return false
+	}
+	for !it.pending {
+		if !it.advance() {
+			return false
+		}
+	}
+	switch {
+	case it.lo == it.hi:
+		it.point, it.pending = it.lo, false
+	case it.reverse:
+		it.point, it.hi = it.hi, it.hi-1
+	default:
+		it.point, it.lo = it.lo, it.lo+1
+	}
+	it.budget -= 1
+	return true
+}
+
+// advance moves on to the integers of the next interval in iteration order.
+func (it *PointIterator) advance() bool {
+	var ival Interval
+	if it.reverse {
+		if it.index < 0 {
+			return false
+		}
+		start, before, _ := it.t.nthStart(uint(it.index))
+		ival, _ = it.t.intervalFrom(start, before)
+		it.index -= 1
+	} else {
+		if !it.more {
+			return false
+		}
+		var end uint
+		ival, end = it.t.intervalFrom(it.start, it.before)
+		it.start, it.before, it.more = it.t.nextStart(it.start, it.before, end)
+	}
+	it.lo, it.hi, it.pending = ival.integers()
+	return true
+}
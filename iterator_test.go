@@ -0,0 +1,208 @@
+package bandit_test
+
+import (
+	"math"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/iancmcc/bandit"
+)
+
+func points(it *PointIterator) []uint64 {
+	var out []uint64
+	for it.Next() {
+		out = append(out, it.Point())
+	}
+	return out
+}
+
+func intervals(it *IntervalIterator) []string {
+	var out []string
+	for it.Next() {
+		ival := it.Interval()
+		out = append(out, ival.String())
+	}
+	return out
+}
+
+var _ = Describe("Iterator", func() {
+
+	Context("iterating over intervals", func() {
+		a := NewIntervalSet(Below(2), Open(2, 5), Closed(10, 20), Point(30), Above(40))
+
+		It("should yield intervals that compare equal to the originals", func() {
+			it := a.Iterator()
+			Ω(it.Next()).Should(BeTrue())
+			Ω(it.Interval().Equals(Below(2))).Should(BeTrue())
+			Ω(it.Next()).Should(BeTrue())
+			Ω(it.Interval().Equals(Open(2, 5))).Should(BeTrue())
+			Ω(intervals(NewIntervalSet(Unbounded()).Iterator())).Should(Equal([]string{"(-∞, ∞)"}))
+		})
+
+		It("should yield the whole domain once for a set with no boundaries", func() {
+			it := NewIntervalSet(Unbounded()).Iterator()
+			Ω(it.Next()).Should(BeTrue())
+			Ω(it.Interval().Equals(Unbounded())).Should(BeTrue())
+			Ω(it.Next()).Should(BeFalse())
+			it.Seek(5)
+			Ω(intervals(it)).Should(Equal([]string{"(-∞, ∞)"}))
+			Ω(intervals(NewIntervalSet().Iterator())).Should(BeEmpty())
+		})
+
+		It("should seek to the interval containing or following a value", func() {
+			it := a.Iterator()
+			it.Seek(15)
+			Ω(intervals(it)).Should(Equal([]string{"[10, 20]", "[30]", "(40, ∞)"}))
+			it.Seek(5)
+			Ω(intervals(it)).Should(Equal([]string{"[10, 20]", "[30]", "(40, ∞)"}))
+			it.Seek(2)
+			Ω(intervals(it)).Should(Equal([]string{"(2, 5)", "[10, 20]", "[30]", "(40, ∞)"}))
+			it.Seek(0)
+			Ω(intervals(it)).Should(Equal([]string{"(-∞, 2)", "(2, 5)", "[10, 20]", "[30]", "(40, ∞)"}))
+			it.Seek(math.MaxUint64)
+			Ω(intervals(it)).Should(Equal([]string{"(40, ∞)"}))
+			it = NewIntervalSet(Closed(1, 2)).Iterator()
+			it.Seek(3)
+			Ω(it.Next()).Should(BeFalse())
+		})
+
+		It("should yield only the clipped intervals within a window", func() {
+			Ω(intervals(a.IteratorWithin(Closed(3, 30)))).Should(Equal([]string{"[3, 5)", "[10, 20]", "[30]"}))
+			Ω(intervals(a.IteratorWithin(Open(20, 30)))).Should(BeEmpty())
+			Ω(intervals(a.IteratorWithin(Below(3)))).Should(Equal([]string{"(-∞, 2)", "(2, 3)"}))
+			Ω(intervals(a.IteratorWithin(Above(45)))).Should(Equal([]string{"(45, ∞)"}))
+			Ω(intervals(a.IteratorWithin(Empty()))).Should(BeEmpty())
+		})
+
+		It("should yield intervals in reverse", func() {
+			Ω(intervals(a.ReverseIterator())).Should(Equal([]string{"(40, ∞)", "[30]", "[10, 20]", "(2, 5)", "(-∞, 2)"}))
+			Ω(intervals(NewIntervalSet(Unbounded()).ReverseIterator())).Should(Equal([]string{"(-∞, ∞)"}))
+			Ω(intervals(NewIntervalSet().ReverseIterator())).Should(BeEmpty())
+		})
+
+		It("should seek backwards to the interval containing or preceding a value", func() {
+			it := a.ReverseIterator()
+			it.Seek(25)
+			Ω(intervals(it)).Should(Equal([]string{"[10, 20]", "(2, 5)", "(-∞, 2)"}))
+			it.Seek(10)
+			Ω(intervals(it)).Should(Equal([]string{"[10, 20]", "(2, 5)", "(-∞, 2)"}))
+			it.Seek(2)
+			Ω(intervals(it)).Should(Equal([]string{"(-∞, 2)"}))
+			it.Seek(40)
+			Ω(intervals(it)).Should(Equal([]string{"[30]", "[10, 20]", "(2, 5)", "(-∞, 2)"}))
+			it = NewIntervalSet(Closed(5, 6)).ReverseIterator()
+			it.Seek(4)
+			Ω(it.Next()).Should(BeFalse())
+		})
+	})
+
+	Context("iterating over sets with holes", func() {
+		a := NewIntervalSet(Below(10), Closed(15, 25), Above(30))
+		a.Difference(a, NewIntervalSet(Point(3), Point(20), Point(40)))
+
+		It("should yield intervals split by holes in reverse", func() {
+			Ω(intervals(a.Iterator())).Should(Equal([]string{"(-∞, 3)", "(3, 10)", "[15, 20)", "(20, 25]", "(30, 40)", "(40, ∞)"}))
+			Ω(intervals(a.ReverseIterator())).Should(Equal([]string{"(40, ∞)", "(30, 40)", "(20, 25]", "[15, 20)", "(3, 10)", "(-∞, 3)"}))
+		})
+
+		It("should seek backwards around holes", func() {
+			it := a.ReverseIterator()
+			it.Seek(20)
+			Ω(intervals(it)).Should(Equal([]string{"[15, 20)", "(3, 10)", "(-∞, 3)"}))
+			it.Seek(21)
+			Ω(intervals(it)).Should(Equal([]string{"(20, 25]", "[15, 20)", "(3, 10)", "(-∞, 3)"}))
+			it.Seek(3)
+			Ω(intervals(it)).Should(Equal([]string{"(-∞, 3)"}))
+			it.Seek(0)
+			Ω(intervals(it)).Should(Equal([]string{"(-∞, 3)"}))
+			it.Seek(math.MaxUint64)
+			Ω(intervals(it)).Should(Equal([]string{"(40, ∞)", "(30, 40)", "(20, 25]", "[15, 20)", "(3, 10)", "(-∞, 3)"}))
+			it.Seek(40)
+			Ω(intervals(it)).Should(Equal([]string{"(30, 40)", "(20, 25]", "[15, 20)", "(3, 10)", "(-∞, 3)"}))
+		})
+
+		It("should seek backwards into the unbounded ends", func() {
+			b := NewIntervalSet(Below(10), Above(30))
+			it := b.ReverseIterator()
+			it.Seek(50)
+			Ω(intervals(it)).Should(Equal([]string{"(30, ∞)", "(-∞, 10)"}))
+			it.Seek(20)
+			Ω(intervals(it)).Should(Equal([]string{"(-∞, 10)"}))
+			it.Seek(5)
+			Ω(intervals(it)).Should(Equal([]string{"(-∞, 10)"}))
+			it = NewIntervalSet(Above(30)).ReverseIterator()
+			it.Seek(10)
+			Ω(it.Next()).Should(BeFalse())
+		})
+	})
+
+	Context("iterating over chunks", func() {
+		a := NewIntervalSet(RightOpen(0, 10), LeftOpen(13, 22), Point(30))
+
+		chunks := func(it *ChunkIterator) []string {
+			var out []string
+			for it.Next() {
+				out = append(out, it.Interval().String())
+			}
+			return out
+		}
+
+		It("should cut long intervals from their start", func() {
+			Ω(chunks(a.Chunk(4))).Should(Equal([]string{"[0, 4)", "[4, 8)", "[8, 10)", "(13, 17)", "[17, 21)", "[21, 22]", "[30]"}))
+		})
+
+		It("should cut long intervals at aligned boundaries", func() {
+			Ω(chunks(a.ChunkAligned(4))).Should(Equal([]string{"[0, 4)", "[4, 8)", "[8, 10)", "(13, 16)", "[16, 20)", "[20, 22]", "[30]"}))
+		})
+
+		It("should leave intervals whole without a limit", func() {
+			Ω(chunks(a.Chunk(0))).Should(Equal([]string{"[0, 10)", "(13, 22]", "[30]"}))
+		})
+
+		It("should cut discrete sets into closed pieces", func() {
+			b := NewIntervalSet(Closed(1, 9)).SetDiscrete(true)
+			Ω(chunks(b.ChunkAligned(4))).Should(Equal([]string{"[1, 3]", "[4, 7]", "[8, 9]"}))
+		})
+
+		It("should stop cutting at the top of the domain", func() {
+			b := NewIntervalSet(AtOrAbove(math.MaxUint64 - 5))
+			Ω(chunks(b.Chunk(4))).Should(Equal([]string{"[18446744073709551610, 18446744073709551614)", "[18446744073709551614, ∞)"}))
+		})
+	})
+
+	Context("iterating over points", func() {
+		a := NewIntervalSet(Closed(1, 3), Open(5, 8), Open(8, 9), Point(10))
+
+		It("should visit each member in order", func() {
+			Ω(points(a.PointIterator(math.MaxUint64))).Should(Equal([]uint64{1, 2, 3, 6, 7, 10}))
+		})
+
+		It("should visit each member in reverse", func() {
+			Ω(points(a.ReversePointIterator(math.MaxUint64))).Should(Equal([]uint64{10, 7, 6, 3, 2, 1}))
+		})
+
+		It("should step around holes", func() {
+			b := NewIntervalSet(Closed(1, 5))
+			b.Difference(b, NewIntervalSet(Point(3)))
+			Ω(points(b.PointIterator(math.MaxUint64))).Should(Equal([]uint64{1, 2, 4, 5}))
+			Ω(points(b.ReversePointIterator(math.MaxUint64))).Should(Equal([]uint64{5, 4, 2, 1}))
+		})
+
+		It("should stop when its budget runs out", func() {
+			Ω(points(a.PointIterator(4))).Should(Equal([]uint64{1, 2, 3, 6}))
+			Ω(points(a.PointIterator(0))).Should(BeEmpty())
+			b := NewIntervalSet(AtOrAbove(0))
+			Ω(points(b.PointIterator(3))).Should(Equal([]uint64{0, 1, 2}))
+			Ω(points(b.ReversePointIterator(2))).Should(Equal([]uint64{math.MaxUint64, math.MaxUint64 - 1}))
+		})
+
+		It("should stop at the edges of the domain", func() {
+			b := NewIntervalSet(Above(math.MaxUint64 - 2))
+			Ω(points(b.PointIterator(10))).Should(Equal([]uint64{math.MaxUint64 - 1, math.MaxUint64}))
+			b = NewIntervalSet(AtOrBelow(1))
+			Ω(points(b.ReversePointIterator(10))).Should(Equal([]uint64{1, 0}))
+			Ω(points(NewIntervalSet(Below(0)).PointIterator(10))).Should(BeEmpty())
+		})
+	})
+})
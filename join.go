@@ -0,0 +1,106 @@
+package bandit
+
+// JoinIterator visits the pairs of keys a join matches, one at a time,
+// without building the joined map.
+type JoinIterator struct {
+	x, y  *IntervalMap
+	f     func(kx, ky interface{}) (interface{}, bool)
+	xkeys []interface{}
+	ykeys []interface{}
+	i, j  int
+	key   interface{}
+	set   *IntervalSet
+}
+
+// NewJoinIterator returns an iterator over the pairs of keys of x and y
+// that f accepts and whose sets overlap. Each is reported under the key f
+// gives it along with the members the two sets share. A nil f pairs each
+// key with itself. A nil map has no keys to pair. x and y mustn't change
+// while the iterator is in use.
+func NewJoinIterator(x, y *IntervalMap, f func(kx, ky interface{}) (interface{}, bool)) *JoinIterator {
+	it := &JoinIterator{x: x, y: y, f: f, set: NewIntervalSet()}
+	if x == nil || y == nil {
+		return it
+	}
+	it.set.SetDiscrete(x.discrete || y.discrete)
+	for k := range x.m {
+		if _, ok := y.m[k]; ok || (f != nil && len(y.m) > 0) {
+			it.xkeys = append(it.xkeys, k)
+		}
+	}
+	if f != nil {
+		for k := range y.m {
+			it.ykeys = append(it.ykeys, k)
+		}
+	}
+	return it
+}
+
+// Value returns the key of the current pair and the members its sets
+// share. The set is reused by the next call to Next.
+func (it *JoinIterator) Value() (interface{}, *IntervalSet) {
+	return it.key, it.set
+}
+
+func (it *JoinIterator) Next() bool {
+	for it.i < len(it.xkeys) {
+		kx, ky := it.xkeys[it.i], it.xkeys[it.i]
+		if it.f == nil {
+			it.i++
+		} else {
+			ky = it.ykeys[it.j]
+			if it.j++; it.j == len(it.ykeys) {
+				it.i, it.j = it.i+1, 0
+			}
+		}
+		if it.match(kx, ky) {
+			return true
+		}
+	}
+	return false
+}
+
+// match intersects the sets of kx and ky if f accepts them, checking that
+// they overlap before doing so.
+func (it *JoinIterator) match(kx, ky interface{}) bool {
+	key := kx
+	if it.f != nil {
+		var ok bool
+		if key, ok = it.f(kx, ky); !ok {
+			return false
+		}
+	}
+	a, b := &it.x.sets[it.x.m[kx]].IntervalSet, &it.y.sets[it.y.m[ky]].IntervalSet
+	if !a.Overlaps(b) {
+		return false
+	}
+	it.key = key
+	return !it.set.Intersection(a, b).IsEmpty()
+}
+
+// Join returns the map holding, for each pair of keys of x and y that f
+// accepts, the members their sets share under the key f gives. Pairs given
+// the same key are merged. A nil f pairs each key with itself, which is
+// Intersection. The result is discrete if either map is, and empty if
+// either is nil.
+func Join(x, y *IntervalMap, f func(kx, ky interface{}) (interface{}, bool)) *IntervalMap {
+	if x == nil {
+		x, y = y, x
+	}
+	if y == nil {
+		z := NewIntervalMap()
+		if x != nil {
+			z.SetDiscrete(x.discrete)
+		}
+		return z
+	}
+	z := NewMap(x.Caps()).SetDiscrete(x.discrete || y.discrete)
+	if f == nil {
+		return z.Intersection(x, y)
+	}
+	for it := NewJoinIterator(x, y, f); it.Next(); {
+		k, set := it.Value()
+		z.AddSet(z, k, set)
+	}
+	return z
+}
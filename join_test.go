@@ -0,0 +1,69 @@
+package bandit_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/iancmcc/bandit"
+)
+
+var _ = Describe("Map joins", func() {
+	var logins, flags *IntervalMap
+
+	BeforeEach(func() {
+		logins = imap("alice", Closed(0, 10), Closed(50, 60))
+		logins.Add(logins, "bob", Closed(20, 40))
+		flags = imap("dark", Closed(5, 25))
+		flags.Add(flags, "beta", Closed(55, 100))
+	})
+
+	pairs := func(kx, ky interface{}) (interface{}, bool) {
+		return fmt.Sprintf("%s/%s", kx, ky), true
+	}
+
+	It("should intersect every matched pair", func() {
+		z := Join(logins, flags, pairs)
+		Ω(z.ValueSlice()).Should(ConsistOf("alice/dark", "alice/beta", "bob/dark"))
+		Ω(z.Get("alice/dark").Equals(NewIntervalSet(Closed(5, 10)))).Should(BeTrue())
+		Ω(z.Get("alice/beta").Equals(NewIntervalSet(Closed(55, 60)))).Should(BeTrue())
+		Ω(z.Get("bob/dark").Equals(NewIntervalSet(Closed(20, 25)))).Should(BeTrue())
+	})
+
+	It("should merge pairs given the same key", func() {
+		z := Join(logins, flags, func(kx, ky interface{}) (interface{}, bool) {
+			return "anyone", ky == "dark"
+		})
+		Ω(z.ValueSlice()).Should(ConsistOf("anyone"))
+		Ω(z.Get("anyone").Equals(NewIntervalSet(Closed(5, 10), Closed(20, 25)))).Should(BeTrue())
+	})
+
+	It("should intersect matching keys without a match function", func() {
+		other := imap("alice", Open(8, 52))
+		other.Add(other, "carol", Unbounded())
+		z := Join(logins, other, nil)
+		Ω(z.Equals(NewIntervalMap().Intersection(logins, other))).Should(BeTrue())
+		Ω(z.ValueSlice()).Should(ConsistOf("alice"))
+	})
+
+	It("should stream matched pairs", func() {
+		seen := make(map[interface{}]string)
+		for it := NewJoinIterator(logins, flags, pairs); it.Next(); {
+			k, set := it.Value()
+			seen[k] = set.String()
+		}
+		Ω(seen).Should(HaveLen(3))
+		Ω(seen["bob/dark"]).Should(Equal(NewIntervalSet(Closed(20, 25)).String()))
+		Ω(NewJoinIterator(logins, NewIntervalMap(), pairs).Next()).Should(BeFalse())
+	})
+
+	It("should treat a nil map as empty", func() {
+		Ω(Join(nil, flags, pairs).IsEmpty()).Should(BeTrue())
+		Ω(Join(logins, nil, nil).IsEmpty()).Should(BeTrue())
+		Ω(Join(nil, nil, pairs).IsEmpty()).Should(BeTrue())
+		Ω(Join(nil, flags.SetDiscrete(true), pairs).IsDiscrete()).Should(BeTrue())
+		Ω(NewJoinIterator(nil, flags, pairs).Next()).Should(BeFalse())
+		Ω(NewJoinIterator(logins, nil, nil).Next()).Should(BeFalse())
+	})
+})
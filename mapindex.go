@@ -0,0 +1,194 @@
+package bandit
+
+import "math"
+
+// keyIndex files each key of a map under the smallest aligned prefix range
+// that holds all of its set, so the keys that might cover a value are found
+// by looking up the one range at each level that holds it.
+type keyIndex struct {
+	blocks map[interface{}]PrefixRange
+	levels [65]map[uint64]map[interface{}]struct{}
+}
+
+// newKeyIndex returns an empty index. Each level's map is made the first
+// time a key is filed there.
+func newKeyIndex() *keyIndex {
+	return &keyIndex{blocks: make(map[interface{}]PrefixRange)}
+}
+
+// clear empties idx, keeping its maps for reuse.
+func (idx *keyIndex) clear() {
+	for k := range idx.blocks {
+		delete(idx.blocks, k)
+	}
+	for _, blocks := range idx.levels {
+		for p := range blocks {
+			delete(blocks, p)
+		}
+	}
+}
+
+// hull returns the least and greatest values that bound the members of t.
+// Membership below 0 or above MaxUint64 counts as reaching 0 or MaxUint64.
+func (t *Tree) hull() (lo, hi uint64) {
+	if t.root == 0 {
+		return 0, math.MaxUint64
+	}
+	root := &t.nodes[t.root]
+	if !t.ul {
+		lo = t.firstLeaf(t.root).prefix
+	}
+	hi = root.last
+	if t.ul != root.ul {
+		hi = math.MaxUint64
+	}
+	return
+}
+
+// blockOf returns the smallest prefix range holding both lo and hi.
+func blockOf(lo, hi uint64) PrefixRange {
+	level := BranchingBit(lo, hi)
+	return PrefixRange{MaskAbove(lo, level), level}
+}
+
+func (idx *keyIndex) remove(k interface{}) {
+	b, ok := idx.blocks[k]
+	if !ok {
+		return
+	}
+	delete(idx.blocks, k)
+	keys := idx.levels[b.Level][b.Prefix]
+	delete(keys, k)
+	if len(keys) == 0 {
+		delete(idx.levels[b.Level], b.Prefix)
+	}
+}
+
+func (idx *keyIndex) put(k interface{}, t *Tree) {
+	b := blockOf(t.hull())
+	if old, ok := idx.blocks[k]; ok {
+		if old == b {
+			return
+		}
+		idx.remove(k)
+	}
+	idx.blocks[k] = b
+	if idx.levels[b.Level] == nil {
+		idx.levels[b.Level] = make(map[uint64]map[interface{}]struct{})
+	}
+	keys, ok := idx.levels[b.Level][b.Prefix]
+	if !ok {
+		keys = make(map[interface{}]struct{})
+		idx.levels[b.Level][b.Prefix] = keys
+	}
+	keys[k] = struct{}{}
+}
+
+// SetIndexed turns the key index on or off. An indexed map keeps track of
+// roughly where each key's set lies as it changes, so KeysAt and
+// KeysOverlapping only look at keys that might match instead of all of
+// them. Sets reached through a MapIterator mustn't be changed in place
+// while the index is on.
+func (z *IntervalMap) SetIndexed(indexed bool) *IntervalMap {
+	z.index = nil
+	if indexed {
+		z.index = newKeyIndex()
+		for k := range z.m {
+			z.touch(k)
+		}
+	}
+	return z
+}
+
+func (z *IntervalMap) IsIndexed() bool {
+	return z.index != nil
+}
+
+// touch brings the index up to date with the set under k, which may have
+// just changed or been removed.
+func (z *IntervalMap) touch(k interface{}) {
+	if z.index == nil {
+		return
+	}
+	idx, ok := z.m[k]
+	if !ok {
+		z.index.remove(k)
+		return
+	}
+	z.index.put(k, &z.sets[idx].Tree)
+}
+
+// KeysAt returns the keys whose sets contain x, in no particular order.
+func (z *IntervalMap) KeysAt(x uint64) []interface{} {
+	var keys []interface{}
+	if z.index == nil {
+		for k, idx := range z.m {
+			if z.sets[idx].contains(x) {
+				keys = append(keys, k)
+			}
+		}
+		return keys
+	}
+	for level := uint(0); level <= 64; level++ {
+		for k := range z.index.levels[level][MaskAbove(x, level)] {
+			if z.sets[z.m[k]].contains(x) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	return keys
+}
+
+// KeysOverlapping returns the keys whose sets share a member with ival, in
+// no particular order. In discrete mode only the uint64 values of ival
+// count.
+func (z *IntervalMap) KeysOverlapping(ival Interval) []interface{} {
+	var keys []interface{}
+	if z.discrete {
+		ival = ival.Normalize()
+	}
+	if ival.IsEmpty() {
+		return keys
+	}
+	overlaps := func(k interface{}) bool {
+		return z.sets[z.m[k]].some(&ival.Tree, overlapping)
+	}
+	if z.index == nil {
+		for k := range z.m {
+			if overlaps(k) {
+				keys = append(keys, k)
+			}
+		}
+		return keys
+	}
+	lo, hi := ival.hull()
+	for level := uint(0); level <= 64; level++ {
+		var (
+			blocks     = z.index.levels[level]
+			first, end = MaskAbove(lo, level), MaskAbove(hi, level)
+			add        = func(ks map[interface{}]struct{}) {
+				for k := range ks {
+					if overlaps(k) {
+						keys = append(keys, k)
+					}
+				}
+			}
+		)
+		if level < 64 && (end-first)>>level < uint64(len(blocks)) {
+			// Fewer ranges to look up than there are in use at this level
+			for p := first; ; p += 1 << level {
+				add(blocks[p])
+				if p == end {
+					break
+				}
+			}
+			continue
+		}
+		for p, ks := range blocks {
+			if p >= first && p <= end {
+				add(ks)
+			}
+		}
+	}
+	return keys
+}
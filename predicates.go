@@ -0,0 +1,151 @@
+package bandit
+
+// leafCursor walks the leaves of a tree in ascending order. Its stack holds
+// at most one pending right child per level, so it never allocates. The
+// tree is passed to each call rather than kept, which would force trees
+// held in Interval values onto the heap.
+type leafCursor struct {
+	stack [65]uint
+	depth int
+}
+
+func (c *leafCursor) reset(t *Tree) {
+	c.depth = 0
+	if t.root != 0 {
+		c.stack[0], c.depth = t.root, 1
+	}
+}
+
+// next returns the following leaf of t, or nil once there are none left.
+func (c *leafCursor) next(t *Tree) *node {
+	for c.depth > 0 {
+		c.depth--
+		n := &t.nodes[c.stack[c.depth]]
+		if n.level == 0 {
+			return n
+		}
+		c.stack[c.depth], c.stack[c.depth+1] = n.right, n.left
+		c.depth += 2
+	}
+	return nil
+}
+
+// contains reports whether x is a member of t.
+func (t *Tree) contains(x uint64) bool {
+	idx, ul := t.root, t.ul
+	for idx != 0 {
+		n := &t.nodes[idx]
+		if !IsPrefixAt(x, n.prefix, uint(n.level)) {
+			if n.prefix < x {
+				// Every boundary under n lies below x
+				return ul != n.ul
+			}
+			return ul
+		}
+		if n.level == 0 {
+			return ul != n.incl
+		}
+		if ZeroAt(x, uint(n.level)) {
+			idx = n.left
+		} else {
+			ul = ul != (&t.nodes[n.left]).ul
+			idx = n.right
+		}
+	}
+	return ul
+}
+
+// some reports whether f holds for membership in t and u at any point,
+// walking the boundaries of both in step and stopping at the first point
+// found. Every boundary and every stretch between two boundaries is
+// checked.
+func (t *Tree) some(u *Tree, f func(a, b bool) bool) bool {
+	var ac, bc leafCursor
+	ac.reset(t)
+	bc.reset(u)
+	a, b := ac.next(t), bc.next(u)
+	ain, bin := t.ul, u.ul
+	if f(ain, bin) {
+		return true
+	}
+	for a != nil || b != nil {
+		at, bt := ain, bin
+		aafter, bafter := ain, bin
+		switch {
+		case b == nil || (a != nil && a.prefix < b.prefix):
+			at, aafter = ain != a.incl, ain != a.ul
+			a = ac.next(t)
+		case a == nil || b.prefix < a.prefix:
+			bt, bafter = bin != b.incl, bin != b.ul
+			b = bc.next(u)
+		default:
+			at, aafter = ain != a.incl, ain != a.ul
+			bt, bafter = bin != b.incl, bin != b.ul
+			a, b = ac.next(t), bc.next(u)
+		}
+		ain, bin = aafter, bafter
+		if f(at, bt) || f(ain, bin) {
+			return true
+		}
+	}
+	return false
+}
+
+func overlapping(a, b bool) bool { return a && b }
+
+func uncovered(a, b bool) bool { return a && !b }
+
+// Contains reports whether x is a member of z.
+func (z *IntervalSet) Contains(x uint64) bool {
+	return z.contains(x)
+}
+
+// ContainsInterval reports whether every member of ival is a member of z.
+// A discrete set only needs to hold the uint64 values of ival.
+func (z *IntervalSet) ContainsInterval(ival Interval) bool {
+	if z.discrete {
+		ival = ival.Normalize()
+	}
+	return !ival.some(&z.Tree, uncovered)
+}
+
+// Overlaps reports whether z and x share any member.
+func (z *IntervalSet) Overlaps(x *IntervalSet) bool {
+	return z.some(&x.Tree, overlapping)
+}
+
+// IsDisjoint reports whether z and x share no member.
+func (z *IntervalSet) IsDisjoint(x *IntervalSet) bool {
+	return !z.Overlaps(x)
+}
+
+// IsSubsetOf reports whether every member of z is a member of x.
+func (z *IntervalSet) IsSubsetOf(x *IntervalSet) bool {
+	return !z.some(&x.Tree, uncovered)
+}
+
+// Contains reports whether x is a member of ival.
+func (ival Interval) Contains(x uint64) bool {
+	return ival.contains(x)
+}
+
+// ContainsInterval reports whether every member of other is a member of
+// ival.
+func (ival Interval) ContainsInterval(other Interval) bool {
+	return !other.some(&ival.Tree, uncovered)
+}
+
+// Overlaps reports whether ival and other share any member.
+func (ival Interval) Overlaps(other Interval) bool {
+	return ival.some(&other.Tree, overlapping)
+}
+
+// IsDisjoint reports whether ival and other share no member.
+func (ival Interval) IsDisjoint(other Interval) bool {
+	return !ival.Overlaps(other)
+}
+
+// IsSubsetOf reports whether every member of ival is a member of other.
+func (ival Interval) IsSubsetOf(other Interval) bool {
+	return !ival.some(&other.Tree, uncovered)
+}
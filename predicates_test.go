@@ -0,0 +1,85 @@
+package bandit_test
+
+import (
+	"math"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	. "github.com/iancmcc/bandit"
+)
+
+var _ = Describe("Set predicates", func() {
+	var (
+		a = NewIntervalSet(Closed(10, 20), Open(25, 30), Point(40))
+		// A hole at 5
+		h = NewIntervalSet(Below(5), Above(5))
+	)
+
+	DescribeTable("testing membership", func(x *IntervalSet, v int, expected bool) {
+		Ω(x.Contains(uint64(v))).Should(Equal(expected))
+	},
+		Entry("closed lower", a, 10, true),
+		Entry("inside", a, 15, true),
+		Entry("between", a, 22, false),
+		Entry("open lower", a, 25, false),
+		Entry("open upper", a, 30, false),
+		Entry("point", a, 40, true),
+		Entry("past the end", a, 41, false),
+		Entry("hole", h, 5, false),
+		Entry("around a hole", h, 4, true),
+	)
+
+	It("should test membership at the domain edges", func() {
+		Ω(NewIntervalSet(Above(math.MaxUint64 - 1)).Contains(math.MaxUint64)).Should(BeTrue())
+		Ω(NewIntervalSet(Below(0)).Contains(0)).Should(BeFalse())
+		Ω(NewIntervalSet(Unbounded()).Contains(0)).Should(BeTrue())
+	})
+
+	DescribeTable("containing intervals", func(x *IntervalSet, ival Interval, expected bool) {
+		Ω(x.ContainsInterval(ival)).Should(Equal(expected))
+		Ω(ival.AsIntervalSet().IsSubsetOf(x)).Should(Equal(expected))
+	},
+		Entry("inside", a, Open(10, 20), true),
+		Entry("whole interval", a, Closed(10, 20), true),
+		Entry("open bound", a, Closed(25, 29), false),
+		Entry("spanning a gap", a, Closed(15, 26), false),
+		Entry("empty", a, Empty(), true),
+		Entry("unbounded", h, Unbounded(), false),
+		Entry("beside a hole", h, Above(5), true),
+	)
+
+	It("should contain the uint64 values of an interval in discrete mode", func() {
+		d := NewIntervalSet(Closed(2, 4)).SetDiscrete(true)
+		Ω(d.ContainsInterval(Open(1, 5))).Should(BeTrue())
+		Ω(NewIntervalSet(Closed(2, 4)).ContainsInterval(Open(1, 5))).Should(BeFalse())
+	})
+
+	DescribeTable("comparing sets", func(x, y *IntervalSet, subset, overlaps bool) {
+		Ω(x.IsSubsetOf(y)).Should(Equal(subset))
+		Ω(x.Overlaps(y)).Should(Equal(overlaps))
+		Ω(y.Overlaps(x)).Should(Equal(overlaps))
+		Ω(x.IsDisjoint(y)).Should(Equal(!overlaps))
+	},
+		Entry("itself", a, a, true, true),
+		Entry("a subset", NewIntervalSet(Closed(12, 14), Point(40)), a, true, true),
+		Entry("a superset", a, NewIntervalSet(Closed(12, 14), Point(40)), false, true),
+		Entry("touching open bounds", NewIntervalSet(Closed(20, 25)), NewIntervalSet(Open(25, 30)), false, false),
+		Entry("sharing a point", NewIntervalSet(Closed(20, 25)), a, false, true),
+		Entry("filling a hole", NewIntervalSet(Point(5)), h, false, false),
+		Entry("around a hole", a, h, true, true),
+		Entry("empty", NewIntervalSet(), a, true, false),
+	)
+
+	It("should compare intervals", func() {
+		Ω(Closed(1, 10).Contains(10)).Should(BeTrue())
+		Ω(RightOpen(1, 10).Contains(10)).Should(BeFalse())
+		Ω(Closed(1, 10).ContainsInterval(Open(1, 10))).Should(BeTrue())
+		Ω(Open(1, 10).ContainsInterval(Closed(1, 10))).Should(BeFalse())
+		Ω(Open(1, 10).IsSubsetOf(Below(10))).Should(BeTrue())
+		Ω(RightOpen(1, 5).Overlaps(Closed(5, 8))).Should(BeFalse())
+		Ω(Closed(1, 5).Overlaps(Closed(5, 8))).Should(BeTrue())
+		Ω(Below(3).IsDisjoint(Above(3))).Should(BeTrue())
+	})
+})
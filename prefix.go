@@ -16,7 +16,7 @@ func init() {
 	for i := uint(0); i < 64; i++ {
 		prefixMasks[i] = math.MaxUint64 << i
 	}
-	for i := uint(1); i < 64; i++ {
+	for i := uint(1); i <= 64; i++ {
 		bitMasks[i] = 1 << (i - 1)
 	}
 }
@@ -3,7 +3,8 @@ package bandit_test
 import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	//. "github.com/iancmcc/bandit"
+
+	. "github.com/iancmcc/bandit"
 )
 
 var _ = Describe("Prefix", func() {
@@ -11,4 +12,10 @@ var _ = Describe("Prefix", func() {
 		Ω(true).Should(BeTrue())
 	})
 
+	It("should test the top bit", func() {
+		Ω(ZeroAt(1<<63, 64)).Should(BeFalse())
+		Ω(ZeroAt(1<<63-1, 64)).Should(BeTrue())
+		Ω(ZeroAt(1, 1)).Should(BeFalse())
+	})
+
 })
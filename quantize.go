@@ -0,0 +1,109 @@
+package bandit
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Quantizing snaps a set to the cells [k*grid, (k+1)*grid), the last of
+// which is cut short at MaxUint64. Anything a continuous set holds below 0
+// or above MaxUint64 lies outside every cell and is left alone.
+
+// outerCells returns the smallest union of aligned cells of size
+// 2^level covering t. Every boundary within a cell lies under a single node
+// at or below that level, so only those nodes are visited, never the leaves
+// beneath them.
+func (t *Tree) outerCells(level uint) *Tree {
+	out := &Tree{nodes: make([]node, 1, len(t.nodes))}
+	var walk func(a uint, before bool)
+	walk = func(a uint, before bool) {
+		n := &t.nodes[a]
+		if uint(n.level) > level {
+			walk(n.left, before)
+			walk(n.right, before != (&t.nodes[n.left]).ul)
+			return
+		}
+		var (
+			start  = MaskAbove(n.prefix, level)
+			end    = start + 1<<level
+			after  = before != n.ul
+			member = true
+		)
+		if n.level == 0 {
+			switch {
+			case n.prefix == start && before && n.ul && n.incl:
+				// An open upper bound right at the start of the cell
+				member = false
+			case n.prefix == math.MaxUint64 && !before && n.ul && !n.incl:
+				// An open lower bound at the very top of the domain
+				member = false
+			}
+		}
+		if member != before {
+			out.toggleLeaf(start, true, true)
+		}
+		if member != after {
+			if level < 64 && end > start {
+				out.toggleLeaf(end, true, true)
+			} else {
+				// The cell runs to the top of the domain
+				out.toggleLeaf(math.MaxUint64, true, false)
+			}
+		}
+	}
+	if t.root != 0 {
+		walk(t.root, t.ul)
+	}
+	out.ul = t.ul
+	return out
+}
+
+// outerInterval returns the smallest union of cells of size grid covering
+// the interval with the given bounds.
+func outerInterval(grid uint64, lb BoundType, lower, upper uint64, ub BoundType) Interval {
+	if lb != UnboundBound && !(lb == OpenBound && lower == math.MaxUint64) {
+		lb, lower = ClosedBound, lower-lower%grid
+	}
+	if ub != UnboundBound && !(ub == OpenBound && upper%grid == 0) {
+		start := upper - upper%grid
+		if start > math.MaxUint64-grid {
+			ub, upper = ClosedBound, math.MaxUint64
+		} else {
+			ub, upper = OpenBound, start+grid
+		}
+	}
+	return NewInterval(lb, lower, upper, ub)
+}
+
+// QuantizeOuter sets z to the smallest union of cells of size grid that
+// covers x, and returns z. A grid of 0 copies x as it is.
+func (z *IntervalSet) QuantizeOuter(x *IntervalSet, grid uint64) *IntervalSet {
+	switch {
+	case x == nil:
+		z.Clear()
+		return z
+	case grid == 0:
+		return z.Copy(x)
+	case grid&(grid-1) == 0:
+		out := x.outerCells(uint(bits.TrailingZeros64(grid)))
+		out.discrete = z.discrete
+		z.Tree = *out
+		return z.normalize()
+	}
+	return z.remap(x, func(lb BoundType, lower, upper uint64, ub BoundType) Interval {
+		return outerInterval(grid, lb, lower, upper, ub)
+	})
+}
+
+// QuantizeInner sets z to the union of the cells of size grid that x
+// covers completely, and returns z. A grid of 0 copies x as it is.
+func (z *IntervalSet) QuantizeInner(x *IntervalSet, grid uint64) *IntervalSet {
+	if x == nil {
+		z.Clear()
+		return z
+	}
+	// The cells x covers are those its complement doesn't touch
+	z.Complement(x)
+	z.QuantizeOuter(z, grid)
+	return z.Complement(z)
+}
@@ -0,0 +1,50 @@
+package bandit_test
+
+import (
+	"math"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	. "github.com/iancmcc/bandit"
+)
+
+var _ = Describe("Quantizing", func() {
+	var (
+		a = NewIntervalSet(Open(65, 130), Point(200), RightOpen(240, 360))
+	)
+
+	DescribeTable("quantizing outward", func(x *IntervalSet, grid int, expected *IntervalSet) {
+		actual := NewIntervalSet().QuantizeOuter(x, uint64(grid))
+		Ω(actual.Equals(expected)).Should(BeTrue(), "%s != %s", actual, expected)
+	},
+		Entry("to minutes", a, 60, NewIntervalSet(RightOpen(60, 240), RightOpen(240, 360))),
+		Entry("to a power of two", a, 64, NewIntervalSet(RightOpen(64, 192), RightOpen(192, 384))),
+		Entry("by nothing", a, 0, a),
+		Entry("unbounded", NewIntervalSet(Below(5), Above(70)), 16, NewIntervalSet(Below(16), AtOrAbove(64))),
+		Entry("across a hole", NewIntervalSet(Below(5), Above(5)), 8, NewIntervalSet(Unbounded())),
+		Entry("an upper bound at a cell edge", NewIntervalSet(Open(3, 16)), 16, NewIntervalSet(RightOpen(0, 16))),
+		Entry("the top cell", NewIntervalSet(Point(math.MaxUint64-1)), 16, NewIntervalSet(Closed(math.MaxUint64-15, math.MaxUint64))),
+		Entry("the top cell off the grid", NewIntervalSet(Point(math.MaxUint64-1)), 10, NewIntervalSet(Closed(math.MaxUint64-5, math.MaxUint64))),
+	)
+
+	DescribeTable("quantizing inward", func(x *IntervalSet, grid int, expected *IntervalSet) {
+		actual := NewIntervalSet().QuantizeInner(x, uint64(grid))
+		Ω(actual.Equals(expected)).Should(BeTrue(), "%s != %s", actual, expected)
+	},
+		Entry("to minutes", a, 60, NewIntervalSet(RightOpen(240, 360))),
+		Entry("to a power of two", a, 64, NewIntervalSet(RightOpen(256, 320))),
+		Entry("unbounded", NewIntervalSet(Below(5), Above(70)), 16, NewIntervalSet(Below(0), AtOrAbove(80))),
+		Entry("across a hole", NewIntervalSet(Below(5), Above(5)), 8, NewIntervalSet(Below(0), AtOrAbove(8))),
+		Entry("the whole domain", NewIntervalSet(Closed(0, math.MaxUint64)), 16, NewIntervalSet(Closed(0, math.MaxUint64))),
+	)
+
+	It("should quantize discrete sets to closed cells", func() {
+		b := NewIntervalSet(Closed(5, 9), Closed(15, 40)).SetDiscrete(true)
+		outer := NewIntervalSet().SetDiscrete(true).QuantizeOuter(b, 10)
+		Ω(outer.Equals(NewIntervalSet(Closed(0, 49)).SetDiscrete(true))).Should(BeTrue(), "%s", outer)
+		inner := NewIntervalSet().SetDiscrete(true).QuantizeInner(b, 8)
+		Ω(inner.Equals(NewIntervalSet(Closed(16, 39)).SetDiscrete(true))).Should(BeTrue(), "%s", inner)
+	})
+})
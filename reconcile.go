@@ -0,0 +1,467 @@
+package bandit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+type (
+	// PrefixRange is an aligned range of the domain: every value whose bits
+	// above Level match Prefix. Level 0 is a single value; level 64 is the
+	// whole domain.
+	PrefixRange struct {
+		Prefix uint64
+		Level  uint
+	}
+
+	// RangeSummary is the Merkle-style digest of the part of a set that
+	// falls within a PrefixRange. Node is the smallest prefix range that
+	// still holds every boundary inside Range, and Hash covers the subtree
+	// rooted there. Before is membership immediately preceding Range.
+	RangeSummary struct {
+		Range  PrefixRange
+		Node   PrefixRange
+		Count  uint64
+		Hash   uint64
+		Before bool
+	}
+
+	// RangeLeaves carries the boundaries of a set within a PrefixRange.
+	RangeLeaves struct {
+		Range  PrefixRange
+		leaves []wireLeaf
+	}
+
+	wireLeaf struct {
+		Prefix uint64
+		UL     bool
+		Incl   bool
+	}
+
+	wireRange struct {
+		Prefix uint64
+		Level  uint8
+	}
+
+	wireSummary struct {
+		Range  wireRange
+		Node   wireRange
+		Count  uint64
+		Hash   uint64
+		Before bool
+	}
+
+	wireHeader struct {
+		A, B uint32
+	}
+)
+
+// Ranges whose source holds no more than this many boundaries are shipped
+// outright instead of being expanded into further summaries.
+const reconcileLeafThreshold = 16
+
+const (
+	fnvOffset uint64 = 14695981039346656037
+	fnvPrime  uint64 = 1099511628211
+)
+
+var (
+	// WholeDomain is the prefix range covering every uint64.
+	WholeDomain = PrefixRange{0, 64}
+
+	ErrReconcileMessage = errors.New("invalid reconcile message")
+)
+
+// valid reports whether r's Level is within the 64 bits of the domain.
+func (r PrefixRange) valid() bool {
+	return r.Level <= 64
+}
+
+func (r PrefixRange) halves() (PrefixRange, PrefixRange) {
+	return PrefixRange{r.Prefix, r.Level - 1},
+		PrefixRange{r.Prefix | bitMasks[r.Level], r.Level - 1}
+}
+
+func (r PrefixRange) wire() wireRange {
+	return wireRange{r.Prefix, uint8(r.Level)}
+}
+
+func (r wireRange) prefixRange() PrefixRange {
+	return PrefixRange{r.Prefix, uint(r.Level)}
+}
+
+func hashWord(h, v uint64) uint64 {
+	for i := 0; i < 8; i++ {
+		h ^= v & 0xff
+		h *= fnvPrime
+		v >>= 8
+	}
+	return h
+}
+
+// hash returns a digest of the subtree rooted at a. Because the trie is
+// canonical for its boundaries, equal subtrees hash equally no matter which
+// set they belong to. Digests are cached by node until cp rewrites it, so
+// summarizing a set that hasn't changed since the last round costs nothing
+// beyond finding the nodes. A cached 0 reads as missing.
+func (t *Tree) hash(a uint) uint64 {
+	if a == 0 {
+		return 0
+	}
+	if a < uint(len(t.hashes)) && t.hashes[a] != 0 {
+		return t.hashes[a]
+	}
+	n := &t.nodes[a]
+	h := hashWord(hashWord(fnvOffset, n.prefix), uint64(n.level))
+	if n.level == 0 {
+		var flags uint64
+		if n.ul {
+			flags |= 1
+		}
+		if n.incl {
+			flags |= 2
+		}
+		h = hashWord(h, flags)
+	} else {
+		h = hashWord(hashWord(h, t.hash(n.left)), t.hash(n.right))
+	}
+	if k := len(t.nodes); len(t.hashes) < k {
+		t.hashes = append(t.hashes, make([]uint64, k-len(t.hashes))...)
+	}
+	t.hashes[a] = h
+	return h
+}
+
+// rangeNode returns the topmost node whose boundaries all fall within r,
+// or 0 if there are none, along with membership immediately before r.
+func (t *Tree) rangeNode(r PrefixRange) (uint, bool) {
+	var (
+		idx = t.root
+		ul  = t.ul
+	)
+	for idx != 0 {
+		n := &t.nodes[idx]
+		if uint(n.level) <= r.Level {
+			p := MaskAbove(n.prefix, r.Level)
+			switch {
+			case p == r.Prefix:
+				return idx, ul
+			case p < r.Prefix:
+				return 0, ul != n.ul
+			}
+			return 0, ul
+		}
+		if !IsPrefixAt(r.Prefix, n.prefix, uint(n.level)) {
+			if r.Prefix > n.prefix {
+				ul = ul != n.ul
+			}
+			return 0, ul
+		}
+		if ZeroAt(r.Prefix, uint(n.level)) {
+			idx = n.left
+		} else {
+			ul = ul != (&t.nodes[n.left]).ul
+			idx = n.right
+		}
+	}
+	return 0, ul
+}
+
+// subtree copies the subtree rooted at a into a standalone tree with no
+// membership below it.
+func (t *Tree) subtree(a uint) *Tree {
+	sub := &Tree{nodes: make([]node, 1, t.nodes[a].count*2+1)}
+	if a != 0 {
+		sub.root = sub.takeOwnership(t, a)
+		(&sub.nodes[sub.root]).parent = 0
+	}
+	return sub
+}
+
+// toggle flips every boundary of z that also appears in x and adds the
+// ones that don't, leaving membership below the first boundary alone. The
+// boundaries are taken as they are, even in discrete mode.
+func (z *IntervalSet) toggle(x *Tree) {
+	if x.root == 0 {
+		return
+	}
+	discrete := z.discrete
+	z.discrete = false
+	z.mergeRoot(&z.Tree, x, z.root, x.root, z.ul, false, xor)
+	z.discrete = discrete
+}
+
+// Summarize describes the parts of z that fall within each of ranges, or
+// within the whole domain if none are given.
+func (z *IntervalSet) Summarize(ranges ...PrefixRange) []RangeSummary {
+	if len(ranges) == 0 {
+		ranges = []PrefixRange{WholeDomain}
+	}
+	summaries := make([]RangeSummary, len(ranges))
+	for i, r := range ranges {
+		idx, before := z.rangeNode(r)
+		s := RangeSummary{Range: r, Before: before}
+		if idx != 0 {
+			n := &z.nodes[idx]
+			s.Node = PrefixRange{n.prefix, uint(n.level)}
+			s.Count = uint64(n.count)
+			s.Hash = z.hash(idx)
+		}
+		summaries[i] = s
+	}
+	return summaries
+}
+
+// RequestRanges compares summaries from a remote replica against z. Local
+// boundaries the remote side can't have are dropped immediately. It
+// returns the ranges that still differ, split in two where another round
+// of summaries is worthwhile, and those whose boundaries should simply be
+// fetched.
+func (z *IntervalSet) RequestRanges(summaries []RangeSummary) (expand, fetch []PrefixRange) {
+	for _, s := range summaries {
+		if s.Range == WholeDomain {
+			z.ul = s.Before
+		}
+		idx, _ := z.rangeNode(s.Range)
+		if s.Count == 0 {
+			z.toggle(z.subtree(idx))
+			continue
+		}
+		if nidx, _ := z.rangeNode(s.Node); nidx != idx {
+			// Nothing remote lies outside s.Node, so drop what we have there
+			inside := z.subtree(nidx)
+			z.toggle(z.subtree(idx))
+			z.toggle(inside)
+			idx, _ = z.rangeNode(s.Node)
+		}
+		if z.hash(idx) == s.Hash {
+			continue
+		}
+		if s.Count <= reconcileLeafThreshold || s.Node.Level == 0 {
+			fetch = append(fetch, s.Node)
+			continue
+		}
+		l, r := s.Node.halves()
+		expand = append(expand, l, r)
+	}
+	return
+}
+
+// ExportRanges collects the boundaries of z within each of ranges.
+func (z *IntervalSet) ExportRanges(ranges ...PrefixRange) []RangeLeaves {
+	out := make([]RangeLeaves, len(ranges))
+	for i, r := range ranges {
+		out[i].Range = r
+		idx, _ := z.rangeNode(r)
+		if idx == 0 {
+			continue
+		}
+		stack := append(make([]uint, 0, 64), idx)
+		for len(stack) > 0 {
+			idx, stack = stack[len(stack)-1], stack[:len(stack)-1]
+			n := &z.nodes[idx]
+			if n.level != 0 {
+				stack = append(stack, n.right, n.left)
+				continue
+			}
+			out[i].leaves = append(out[i].leaves, wireLeaf{n.prefix, n.ul, n.incl})
+		}
+	}
+	return out
+}
+
+// ApplyRanges replaces the boundaries of z within each range with those
+// exported from a remote replica.
+func (z *IntervalSet) ApplyRanges(ranges []RangeLeaves) {
+	for _, rl := range ranges {
+		idx, _ := z.rangeNode(rl.Range)
+		z.toggle(z.subtree(idx))
+		remote := &Tree{nodes: make([]node, 1, 2*len(rl.leaves)+1)}
+		for _, l := range rl.leaves {
+			leaf := remote.node(l.Prefix, 0, 0, 0, l.UL, l.Incl)
+			remote.mergeRoot(remote, remote, remote.root, leaf, false, false, xor)
+		}
+		z.toggle(remote)
+	}
+}
+
+// Reconcile brings z in line with the set being served by ServeReconcile on
+// the other end of rw. Only ranges whose summaries differ are transferred.
+func (z *IntervalSet) Reconcile(rw io.ReadWriter) error {
+	var expand, fetch []PrefixRange
+	for asked := 1; ; asked = len(expand) {
+		summaries, leaves, err := readReconcileBatch(rw, asked, len(fetch))
+		if err != nil {
+			return err
+		}
+		z.ApplyRanges(leaves)
+		expand, fetch = z.RequestRanges(summaries)
+		if err := writeReconcileRequest(rw, expand, fetch); err != nil {
+			return err
+		}
+		if len(expand) == 0 && len(fetch) == 0 {
+			// The remote set may not be in discrete form
+			z.normalize()
+			return nil
+		}
+	}
+}
+
+// ServeReconcile answers a peer running Reconcile until that peer has
+// nothing left to ask for.
+func (z *IntervalSet) ServeReconcile(rw io.ReadWriter) error {
+	summaries := z.Summarize()
+	if err := writeReconcileBatch(rw, summaries, nil); err != nil {
+		return err
+	}
+	for {
+		// Each summary sent is answered by two halves or one fetch at most
+		expand, fetch, err := readReconcileRequest(rw, 2*len(summaries))
+		if err != nil {
+			return err
+		}
+		if len(expand) == 0 && len(fetch) == 0 {
+			return nil
+		}
+		summaries = nil
+		if len(expand) > 0 {
+			// With no ranges, Summarize would describe the whole domain
+			summaries = z.Summarize(expand...)
+		}
+		if err := writeReconcileBatch(rw, summaries, z.ExportRanges(fetch...)); err != nil {
+			return err
+		}
+	}
+}
+
+// writeMessage encodes each of data in turn and sends the result with a
+// single Write.
+func writeMessage(w io.Writer, data ...interface{}) error {
+	var buf bytes.Buffer
+	for _, d := range data {
+		if err := binary.Write(&buf, binary.BigEndian, d); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Each message is assembled in memory and sent with a single Write, so
+// synchronous transports like net.Pipe see exactly one write per message.
+func writeReconcileBatch(w io.Writer, summaries []RangeSummary, leaves []RangeLeaves) error {
+	data := make([]interface{}, 0, 1+len(summaries)+3*len(leaves))
+	data = append(data, wireHeader{uint32(len(summaries)), uint32(len(leaves))})
+	for _, s := range summaries {
+		data = append(data, wireSummary{
+			Range:  s.Range.wire(),
+			Node:   s.Node.wire(),
+			Count:  s.Count,
+			Hash:   s.Hash,
+			Before: s.Before,
+		})
+	}
+	for _, rl := range leaves {
+		data = append(data, rl.Range.wire(), uint32(len(rl.leaves)), rl.leaves)
+	}
+	return writeMessage(w, data...)
+}
+
+// readReconcileBatch reads a batch answering a request for maxSummaries
+// summaries and maxRanges ranges of boundaries. Counts beyond those are
+// rejected before anything is allocated for them.
+func readReconcileBatch(r io.Reader, maxSummaries, maxRanges int) ([]RangeSummary, []RangeLeaves, error) {
+	var h wireHeader
+	if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+		return nil, nil, err
+	}
+	if uint64(h.A) > uint64(maxSummaries) || uint64(h.B) > uint64(maxRanges) {
+		return nil, nil, ErrReconcileMessage
+	}
+	ws := make([]wireSummary, h.A)
+	if err := binary.Read(r, binary.BigEndian, ws); err != nil {
+		return nil, nil, err
+	}
+	summaries := make([]RangeSummary, h.A)
+	for i, s := range ws {
+		summaries[i] = RangeSummary{
+			Range:  s.Range.prefixRange(),
+			Node:   s.Node.prefixRange(),
+			Count:  s.Count,
+			Hash:   s.Hash,
+			Before: s.Before,
+		}
+		if !summaries[i].Range.valid() || !summaries[i].Node.valid() {
+			return nil, nil, ErrReconcileMessage
+		}
+	}
+	leaves := make([]RangeLeaves, h.B)
+	for i := range leaves {
+		var (
+			wr wireRange
+			n  uint32
+		)
+		if err := binary.Read(r, binary.BigEndian, &wr); err != nil {
+			return nil, nil, err
+		}
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, nil, err
+		}
+		if n > reconcileLeafThreshold {
+			// Only ranges this small are ever fetched
+			return nil, nil, ErrReconcileMessage
+		}
+		if leaves[i].Range = wr.prefixRange(); !leaves[i].Range.valid() {
+			return nil, nil, ErrReconcileMessage
+		}
+		leaves[i].leaves = make([]wireLeaf, n)
+		if err := binary.Read(r, binary.BigEndian, leaves[i].leaves); err != nil {
+			return nil, nil, err
+		}
+	}
+	return summaries, leaves, nil
+}
+
+func writeReconcileRequest(w io.Writer, expand, fetch []PrefixRange) error {
+	data := make([]interface{}, 0, 1+len(expand)+len(fetch))
+	data = append(data, wireHeader{uint32(len(expand)), uint32(len(fetch))})
+	for _, r := range expand {
+		data = append(data, r.wire())
+	}
+	for _, r := range fetch {
+		data = append(data, r.wire())
+	}
+	return writeMessage(w, data...)
+}
+
+// readReconcileRequest reads a request for no more than maxRanges ranges
+// in all.
+func readReconcileRequest(r io.Reader, maxRanges int) (expand, fetch []PrefixRange, err error) {
+	var h wireHeader
+	if err = binary.Read(r, binary.BigEndian, &h); err != nil {
+		return
+	}
+	if uint64(h.A)+uint64(h.B) > uint64(maxRanges) {
+		err = ErrReconcileMessage
+		return
+	}
+	wr := make([]wireRange, h.A+h.B)
+	if err = binary.Read(r, binary.BigEndian, wr); err != nil {
+		return
+	}
+	for i, r := range wr {
+		pr := r.prefixRange()
+		switch {
+		case !pr.valid(), uint32(i) < h.A && pr.Level == 0:
+			// Single values can't be split any further
+			return nil, nil, ErrReconcileMessage
+		case uint32(i) < h.A:
+			expand = append(expand, pr)
+		default:
+			fetch = append(fetch, pr)
+		}
+	}
+	return
+}
@@ -0,0 +1,200 @@
+package bandit_test
+
+import (
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/iancmcc/bandit"
+)
+
+func randomInterval(r *rand.Rand, max uint64) Interval {
+	a := uint64(r.Int63n(int64(max)))
+	b := a + uint64(r.Int63n(int64(max/32+1)))
+	bounds := []BoundType{ClosedBound, OpenBound}
+	lb, ub := bounds[r.Intn(2)], bounds[r.Intn(2)]
+	if r.Intn(200) == 0 {
+		lb = UnboundBound
+	}
+	if r.Intn(200) == 0 {
+		ub = UnboundBound
+	}
+	return NewInterval(lb, a, b, ub)
+}
+
+func randomSet(r *rand.Rand, n int, max uint64) *IntervalSet {
+	s := NewIntervalSet()
+	for i := 0; i < n; i++ {
+		s.Add(s, randomInterval(r, max))
+	}
+	return s
+}
+
+func reconcile(src, dst *IntervalSet) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- src.ServeReconcile(a)
+	}()
+	Ω(dst.Reconcile(b)).ShouldNot(HaveOccurred())
+	Ω(<-errc).ShouldNot(HaveOccurred())
+}
+
+var _ = Describe("Reconcile", func() {
+
+	It("should agree on summaries of identical sets", func() {
+		a := NewIntervalSet(Closed(1, 10), Open(20, 30), Above(1000))
+		b := NewIntervalSet(Above(1000), Open(20, 30), Closed(1, 10))
+		Ω(a.Summarize()).Should(Equal(b.Summarize()))
+		b.Add(b, Point(15))
+		Ω(a.Summarize()).ShouldNot(Equal(b.Summarize()))
+	})
+
+	It("should keep summaries current as a set changes", func() {
+		r := rand.New(rand.NewSource(3))
+		var (
+			a      = randomSet(r, 200, 1<<20)
+			b      = NewIntervalSet()
+			ranges = []PrefixRange{WholeDomain, {Prefix: 0, Level: 19}, {Prefix: 1 << 19, Level: 19}}
+		)
+		for i := 0; i < 200; i++ {
+			a.Summarize(ranges...)
+			b.Summarize(ranges...)
+			if r.Intn(2) == 0 {
+				a.Add(a, randomInterval(r, 1<<20))
+			} else {
+				a.Difference(a, NewIntervalSet(randomInterval(r, 1<<20)))
+			}
+			expected := CopySet(a).Summarize(ranges...)
+			Ω(a.Summarize(ranges...)).Should(Equal(expected))
+			Ω(b.Copy(a).Summarize(ranges...)).Should(Equal(expected))
+		}
+	})
+
+	It("should request nothing when sets are already equal", func() {
+		r := rand.New(rand.NewSource(1))
+		a := randomSet(r, 200, 1<<40)
+		b := CopySet(a)
+		expand, fetch := b.RequestRanges(a.Summarize())
+		Ω(expand).Should(BeEmpty())
+		Ω(fetch).Should(BeEmpty())
+	})
+
+	It("should only expand ranges that differ", func() {
+		a := NewIntervalSet()
+		for i := uint64(0); i < 100; i++ {
+			a.Add(a, Closed(i*10, i*10+5))
+		}
+		b := CopySet(a)
+		b.Add(b, Point(907))
+		summaries := a.Summarize()
+		for {
+			expand, fetch := b.RequestRanges(summaries)
+			if len(expand) == 0 {
+				Ω(fetch).Should(HaveLen(1))
+				b.ApplyRanges(a.ExportRanges(fetch...))
+				break
+			}
+			Ω(expand).Should(HaveLen(2))
+			summaries = a.Summarize(expand...)
+		}
+		Ω(b.Equals(a)).Should(BeTrue(), "%s != %s", b, a)
+	})
+
+	It("should reconcile over a connection", func() {
+		r := rand.New(rand.NewSource(2))
+		for i := 0; i < 50; i++ {
+			a := randomSet(r, r.Intn(300), 1<<uint(r.Intn(60)+4))
+			b := CopySet(a)
+			b.SymmetricDifference(b, randomSet(r, r.Intn(10), 1<<40))
+			reconcile(a, b)
+			Ω(b.Equals(a)).Should(BeTrue(), "%s != %s", b, a)
+		}
+	})
+
+	It("should reconcile sets on both sides of the top bit", func() {
+		const top = 1 << 63
+		a := NewIntervalSet(Closed(5, 10), RightOpen(top+5, top+100))
+		b := NewIntervalSet(Closed(7, 10))
+		reconcile(a, b)
+		Ω(b.Equals(a)).Should(BeTrue(), "%s != %s", b, a)
+		c := NewIntervalSet(Point(top-1), Point(top), Above(top+50))
+		reconcile(c, b)
+		Ω(b.Equals(c)).Should(BeTrue(), "%s != %s", b, c)
+	})
+
+	It("should reject oversized messages", func() {
+		a, b := net.Pipe()
+		defer a.Close()
+		defer b.Close()
+		go binary.Write(a, binary.BigEndian, [2]uint32{1 << 31, 1 << 31})
+		Ω(NewIntervalSet().Reconcile(b)).Should(Equal(ErrReconcileMessage))
+	})
+
+	It("should reject ranges beyond the domain", func() {
+		a, b := net.Pipe()
+		defer a.Close()
+		defer b.Close()
+		// A batch of one summary whose range sits at level 200
+		go binary.Write(a, binary.BigEndian, struct {
+			A, B        uint32
+			Prefix      uint64
+			Level       uint8
+			Node        uint64
+			NodeLevel   uint8
+			Count, Hash uint64
+			Before      bool
+		}{A: 1, Level: 200})
+		Ω(NewIntervalSet().Reconcile(b)).Should(Equal(ErrReconcileMessage))
+	})
+
+	It("should refuse to split a single value", func() {
+		a, b := net.Pipe()
+		defer a.Close()
+		defer b.Close()
+		served := make(chan error, 1)
+		go func() { served <- NewIntervalSet(Closed(1, 100)).ServeReconcile(a) }()
+		// Skip the opening summary, then ask to expand the range at 0
+		_, err := io.ReadFull(b, make([]byte, 8+35))
+		Ω(err).ShouldNot(HaveOccurred())
+		go binary.Write(b, binary.BigEndian, struct {
+			A, B   uint32
+			Prefix uint64
+			Level  uint8
+		}{A: 1})
+		Ω(<-served).Should(Equal(ErrReconcileMessage))
+	})
+
+	It("should reconcile unbounded and empty sets", func() {
+		for _, pair := range [][2]*IntervalSet{
+			{NewIntervalSet(Unbounded()), NewIntervalSet(Closed(1, 2))},
+			{NewIntervalSet(), NewIntervalSet(Below(20), Above(30))},
+			{NewIntervalSet(Below(20), Above(30)), NewIntervalSet()},
+			{NewIntervalSet(AtOrBelow(20)), NewIntervalSet(Below(20))},
+		} {
+			reconcile(pair[0], pair[1])
+			Ω(pair[1].Equals(pair[0])).Should(BeTrue(), "%s != %s", pair[1], pair[0])
+		}
+	})
+
+	It("should reconcile discrete sets", func() {
+		r := rand.New(rand.NewSource(3))
+		for i := 0; i < 50; i++ {
+			a := randomSet(r, r.Intn(300), 1<<20).SetDiscrete(true)
+			b := CopySet(a)
+			b.SymmetricDifference(b, randomSet(r, r.Intn(10), 1<<20))
+			reconcile(a, b)
+			Ω(b.Equals(a)).Should(BeTrue(), "%s != %s", b, a)
+		}
+		a := NewIntervalSet(Closed(1, 10), Open(20, 30))
+		b := NewIntervalSet(Closed(5, 25)).SetDiscrete(true)
+		reconcile(a, b)
+		Ω(b.Equals(NewIntervalSet(Closed(1, 10), Closed(21, 29)))).Should(BeTrue(), "%s", b)
+	})
+})
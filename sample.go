@@ -0,0 +1,258 @@
+package bandit
+
+import (
+	"math"
+	"math/bits"
+	"math/rand"
+	"sort"
+)
+
+// members returns the number of uint64 values in t, which only reaches 2^64
+// (hi set) when t holds the whole domain. It reads the node measures rather
+// than walking the tree.
+func (t *Tree) members() (hi, lo uint64) {
+	if t.root == 0 {
+		if t.ul {
+			return 1, 0
+		}
+		return 0, 0
+	}
+	var (
+		root = &t.nodes[t.root]
+		c    uint64
+	)
+	if t.ul {
+		lo = t.firstLeaf(t.root).prefix
+	}
+	lo += t.inner(t.root, t.ul)
+	if t.ul != root.lastIn {
+		lo, c = bits.Add64(lo, 1, 0)
+		hi += c
+	}
+	if t.ul != root.ul {
+		lo, c = bits.Add64(lo, math.MaxUint64-root.last, 0)
+		hi += c
+	}
+	return
+}
+
+// selectMember returns the member of t with k members below it, descending
+// through the node measures.
+func (t *Tree) selectMember(k uint64) uint64 {
+	if t.root == 0 {
+		return k
+	}
+	first := t.firstLeaf(t.root)
+	if t.ul {
+		if k < first.prefix {
+			return k
+		}
+		k -= first.prefix
+	}
+	in := t.inner(t.root, t.ul)
+	if k < in {
+		return t.selectInner(t.root, t.ul, k, first.prefix)
+	}
+	k -= in
+	root := &t.nodes[t.root]
+	if t.ul != root.lastIn {
+		if k == 0 {
+			return root.last
+		}
+		k--
+	}
+	return root.last + 1 + k
+}
+
+// selectInner returns the member with k members below it between the first
+// and last leaves under a, given membership immediately before a and the
+// prefix of the first leaf under a. Wherever membership runs into a subtree,
+// k is kept as a position instead, first plus the rank, which each step can
+// update without looking up where a subtree starts.
+func (t *Tree) selectInner(a uint, before bool, k, first uint64) uint64 {
+	if before {
+		k += first
+	}
+	for {
+		n := &t.nodes[a]
+		if n.level == 0 {
+			// Only a position in the gap before this leaf leads here
+			return k
+		}
+		l := &t.nodes[n.left]
+		if before {
+			// The left side holds l.last - first - l.measure members
+			if k < l.last-l.measure {
+				a = n.left
+				continue
+			}
+			k -= l.last - l.measure
+		} else {
+			if k < l.measure {
+				a = n.left
+				continue
+			}
+			k -= l.measure
+		}
+		if before != l.lastIn {
+			if k == 0 {
+				return l.last
+			}
+			k--
+		}
+		before = before != l.ul
+		if before {
+			k += l.last + 1
+		}
+		a = n.right
+	}
+}
+
+// uint64n returns a uniform value in [0, n), or any uint64 if n is 0.
+func uint64n(r *rand.Rand, n uint64) uint64 {
+	if n&(n-1) == 0 {
+		return r.Uint64() & (n - 1)
+	}
+	// Reject the 2^64 mod n values that would bias the remainder
+	threshold := -n % n
+	for {
+		if v := r.Uint64(); v >= threshold {
+			return v % n
+		}
+	}
+}
+
+// SamplePoint returns a member of z drawn uniformly from its uint64 values,
+// and false if it has none.
+func (z *IntervalSet) SamplePoint(r *rand.Rand) (uint64, bool) {
+	hi, lo := z.members()
+	if hi == 0 && lo == 0 {
+		return 0, false
+	}
+	// lo is 0 when z holds all 2^64 values, which uint64n covers
+	return z.selectMember(uint64n(r, lo)), true
+}
+
+// SamplePoints returns n distinct members of z drawn uniformly from its
+// uint64 values, in ascending order. If z holds fewer than n values, it
+// returns all of them.
+func (z *IntervalSet) SamplePoints(r *rand.Rand, n int) []uint64 {
+	if n <= 0 {
+		return nil
+	}
+	hi, lo := z.members()
+	if hi == 0 && uint64(n) >= lo {
+		points := make([]uint64, 0, lo)
+		for it := z.PointIterator(lo); it.Next(); {
+			points = append(points, it.Point())
+		}
+		return points
+	}
+	// Floyd's algorithm picks n distinct ranks out of the lo members, where
+	// lo is 0 for all 2^64 of them
+	var (
+		picked = make(map[uint64]struct{}, n)
+		ranks  = make([]uint64, 0, n)
+	)
+	for j := lo - uint64(n); j != lo; j++ {
+		k := uint64n(r, j+1)
+		if _, ok := picked[k]; ok {
+			k = j
+		}
+		picked[k] = struct{}{}
+		ranks = append(ranks, k)
+	}
+	sort.Slice(ranks, func(i, j int) bool { return ranks[i] < ranks[j] })
+	for i, k := range ranks {
+		ranks[i] = z.selectMember(k)
+	}
+	return ranks
+}
+
+// runEnd returns the last value of the run of members of t holding v,
+// which must be a member.
+func (t *Tree) runEnd(v uint64) uint64 {
+	idx, after := t.floorLeaf(v)
+	if n := &t.nodes[idx]; idx != 0 && n.prefix == v {
+		if !after {
+			return v
+		}
+		idx = t.nextLeaf(idx)
+	} else if idx == 0 {
+		idx, _ = t.leftmostLeaf(t.root, false)
+	} else {
+		idx = t.nextLeaf(idx)
+	}
+	if idx == 0 {
+		return math.MaxUint64
+	}
+	if n := &t.nodes[idx]; !n.incl {
+		// Membership carries on through the next leaf
+		return n.prefix
+	}
+	return t.nodes[idx].prefix - 1
+}
+
+// sampleTries bounds how many members SampleInterval draws in the hope of
+// one that starts an interval of the given length.
+const sampleTries = 32
+
+// SampleInterval returns a closed interval holding length uint64 values,
+// every one a member of z, with its lower bound drawn uniformly from every
+// value at which such an interval could start. It returns false if there
+// is none.
+//
+// It draws members as SamplePoint does until one starts such an interval,
+// which takes O(log n) each. If that keeps failing, because few members are
+// far enough from the end of their interval, it counts the starts of every
+// interval instead, in O(n).
+func (z *IntervalSet) SampleInterval(r *rand.Rand, length uint64) (Interval, bool) {
+	if length == 0 {
+		return Empty(), false
+	}
+	if hi, lo := z.members(); hi == 0 && lo < length {
+		return Empty(), false
+	} else if hi == 0 {
+		for i := 0; i < sampleTries; i++ {
+			// Every member is as likely as any other, so each that fits is too
+			start := z.selectMember(uint64n(r, lo))
+			if end := z.runEnd(start); end-start >= length-1 {
+				return Closed(start, start+(length-1)), true
+			}
+		}
+	}
+	// starts calls f with the range of lower bounds that fit within each
+	// interval of z, until f returns false
+	starts := func(f func(lo, hi uint64) bool) {
+		z.eachInterval(func(lb BoundType, lower, upper uint64, ub BoundType) bool {
+			if lo, hi, ok := integerRange(lb, lower, upper, ub); ok && hi-lo >= length-1 {
+				return f(lo, hi-(length-1))
+			}
+			return true
+		})
+	}
+	var hi, lo, c uint64
+	starts(func(first, last uint64) bool {
+		lo, c = bits.Add64(lo, last-first, 0)
+		hi += c
+		lo, c = bits.Add64(lo, 1, 0)
+		hi += c
+		return true
+	})
+	if hi == 0 && lo == 0 {
+		return Empty(), false
+	}
+	var (
+		k     = uint64n(r, lo)
+		start uint64
+	)
+	starts(func(first, last uint64) bool {
+		if k <= last-first {
+			start = first + k
+			return false
+		}
+		k -= last - first + 1
+		return true
+	})
+	return Closed(start, start+(length-1)), true
+}
@@ -0,0 +1,98 @@
+package bandit_test
+
+import (
+	"math"
+	"math/rand"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/iancmcc/bandit"
+)
+
+var _ = Describe("Set sampling", func() {
+	var (
+		r *rand.Rand
+		a = NewIntervalSet(Closed(10, 12), Open(20, 23), Point(40))
+	)
+
+	BeforeEach(func() {
+		r = rand.New(rand.NewSource(1))
+	})
+
+	It("should sample members uniformly", func() {
+		counts := make(map[uint64]int)
+		for i := 0; i < 6000; i++ {
+			x, ok := a.SamplePoint(r)
+			Ω(ok).Should(BeTrue())
+			counts[x]++
+		}
+		Ω(counts).Should(HaveLen(6))
+		for x, n := range counts {
+			Ω(a.Contains(x)).Should(BeTrue())
+			Ω(n).Should(BeNumerically("~", 1000, 150))
+		}
+	})
+
+	It("should sample from unbounded sets", func() {
+		h := NewIntervalSet(Below(5), Above(5))
+		for i := 0; i < 100; i++ {
+			x, ok := h.SamplePoint(r)
+			Ω(ok).Should(BeTrue())
+			Ω(x).ShouldNot(BeNumerically("==", 5))
+		}
+		_, ok := NewIntervalSet(Unbounded()).SamplePoint(r)
+		Ω(ok).Should(BeTrue())
+	})
+
+	It("should find nothing to sample in an empty set", func() {
+		_, ok := NewIntervalSet(Open(1, 2)).SamplePoint(r)
+		Ω(ok).Should(BeFalse())
+		Ω(NewIntervalSet().SamplePoints(r, 3)).Should(BeEmpty())
+	})
+
+	It("should sample distinct points", func() {
+		points := a.SamplePoints(r, 4)
+		Ω(points).Should(HaveLen(4))
+		for i, x := range points {
+			Ω(a.Contains(x)).Should(BeTrue())
+			if i > 0 {
+				Ω(x).Should(BeNumerically(">", points[i-1]))
+			}
+		}
+		Ω(a.SamplePoints(r, 10)).Should(Equal([]uint64{10, 11, 12, 21, 22, 40}))
+		Ω(NewIntervalSet(AtOrAbove(math.MaxUint64-1)).SamplePoints(r, 2)).Should(Equal([]uint64{math.MaxUint64 - 1, math.MaxUint64}))
+	})
+
+	It("should sample intervals that fit", func() {
+		counts := make(map[uint64]int)
+		for i := 0; i < 3000; i++ {
+			ival, ok := a.SampleInterval(r, 2)
+			Ω(ok).Should(BeTrue())
+			Ω(a.ContainsInterval(ival)).Should(BeTrue())
+			Ω(ival.Upper() - ival.Lower()).Should(BeNumerically("==", 1))
+			counts[ival.Lower()]++
+		}
+		Ω(counts).Should(HaveLen(3))
+		for _, n := range counts {
+			Ω(n).Should(BeNumerically("~", 1000, 150))
+		}
+		_, ok := a.SampleInterval(r, 4)
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("should sample intervals that few members can start", func() {
+		b := NewIntervalSet(Closed(5000, 5002))
+		for i := uint64(0); i < 1000; i++ {
+			b.Add(b, Point(i*2))
+		}
+		counts := make(map[uint64]int)
+		for i := 0; i < 2000; i++ {
+			ival, ok := b.SampleInterval(r, 2)
+			Ω(ok).Should(BeTrue())
+			counts[ival.Lower()]++
+		}
+		Ω(counts).Should(HaveLen(2))
+		Ω(counts[5000]).Should(BeNumerically("~", 1000, 150))
+	})
+})
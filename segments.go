@@ -0,0 +1,199 @@
+package bandit
+
+import "container/heap"
+
+type (
+	// Segment is a stretch of the domain over which the same keys of a map
+	// hold.
+	Segment struct {
+		Interval Interval
+		Keys     []interface{}
+	}
+	// SegmentIterator sweeps the boundaries of every set in a map at once,
+	// visiting each maximal stretch covered by a fixed group of keys in
+	// ascending order.
+	SegmentIterator struct {
+		cursors  []segmentCursor
+		queue    segmentQueue
+		active   []int
+		batch    []int
+		discrete bool
+		lb       BoundType
+		lower    uint64
+		pending  []Segment
+		done     bool
+		seg      Segment
+	}
+	segmentCursor struct {
+		key  interface{}
+		t    *Tree
+		c    leafCursor
+		leaf *node
+		in   bool
+		pos  int
+	}
+	// segmentQueue orders cursors by the prefix of their next leaf.
+	segmentQueue struct {
+		cursors []segmentCursor
+		idx     []int
+	}
+)
+
+func (q *segmentQueue) Len() int { return len(q.idx) }
+func (q *segmentQueue) Less(i, j int) bool {
+	return q.cursors[q.idx[i]].leaf.prefix < q.cursors[q.idx[j]].leaf.prefix
+}
+func (q *segmentQueue) Swap(i, j int)      { q.idx[i], q.idx[j] = q.idx[j], q.idx[i] }
+func (q *segmentQueue) Push(x interface{}) { q.idx = append(q.idx, x.(int)) }
+func (q *segmentQueue) Pop() interface{} {
+	i := q.idx[len(q.idx)-1]
+	q.idx = q.idx[:len(q.idx)-1]
+	return i
+}
+
+// Segments returns an iterator over the maximal intervals within which the
+// keys of z holding are the same, in ascending order. Stretches no key
+// holds are skipped. In discrete mode each interval is narrowed to the
+// uint64 values it holds. z mustn't change while the iterator is in use.
+func (z *IntervalMap) Segments() *SegmentIterator {
+	it := &SegmentIterator{
+		cursors:  make([]segmentCursor, 0, len(z.m)),
+		discrete: z.discrete,
+		lb:       UnboundBound,
+	}
+	for k, idx := range z.m {
+		t := &z.sets[idx].Tree
+		it.cursors = append(it.cursors, segmentCursor{key: k, t: t, in: t.ul, pos: -1})
+	}
+	it.queue.cursors = it.cursors
+	for i := range it.cursors {
+		c := &it.cursors[i]
+		if c.in {
+			it.enter(i)
+		}
+		c.c.reset(c.t)
+		if c.leaf = c.c.next(c.t); c.leaf != nil {
+			it.queue.idx = append(it.queue.idx, i)
+		}
+	}
+	heap.Init(&it.queue)
+	return it
+}
+
+func (it *SegmentIterator) Segment() Segment {
+	return it.seg
+}
+
+// Value returns the interval and keys of the current segment.
+func (it *SegmentIterator) Value() (Interval, []interface{}) {
+	return it.seg.Interval, it.seg.Keys
+}
+
+func (it *SegmentIterator) Next() bool {
+	for len(it.pending) == 0 {
+		if it.done {
+			return false
+		}
+		it.advance()
+	}
+	it.seg, it.pending = it.pending[0], it.pending[1:]
+	return true
+}
+
+// advance handles the next boundary of any set, queueing the segments it
+// closes.
+func (it *SegmentIterator) advance() {
+	if it.queue.Len() == 0 {
+		it.close(0, UnboundBound)
+		it.done = true
+		return
+	}
+	p := it.cursors[it.queue.idx[0]].leaf.prefix
+	it.batch = it.batch[:0]
+	for it.queue.Len() > 0 && it.cursors[it.queue.idx[0]].leaf.prefix == p {
+		it.batch = append(it.batch, heap.Pop(&it.queue).(int))
+	}
+	// Keys change at p where a leaf is inclusive, and again just past p
+	// where inclusion and the change in membership disagree
+	if it.toggle(p, OpenBound, func(n *node) bool { return n.incl }) {
+		it.lb, it.lower = ClosedBound, p
+	}
+	if it.toggle(p, ClosedBound, func(n *node) bool { return n.incl != n.ul }) {
+		it.lb, it.lower = OpenBound, p
+	}
+	for _, i := range it.batch {
+		c := &it.cursors[i]
+		if c.leaf = c.c.next(c.t); c.leaf != nil {
+			heap.Push(&it.queue, i)
+		}
+	}
+}
+
+// close queues the segment running from the last boundary up to upper,
+// if any key holds within it.
+func (it *SegmentIterator) close(upper uint64, ub BoundType) {
+	if len(it.active) == 0 {
+		return
+	}
+	ival := NewInterval(it.lb, it.lower, upper, ub)
+	if it.discrete {
+		ival = ival.Normalize()
+	}
+	if ival.IsEmpty() {
+		return
+	}
+	keys := make([]interface{}, len(it.active))
+	for j, i := range it.active {
+		keys[j] = it.cursors[i].key
+	}
+	it.pending = append(it.pending, Segment{ival, keys})
+}
+
+// toggle flips whether the key holds for each cursor in the batch whose
+// leaf satisfies f, first closing the segment at p with bound ub. It
+// reports whether any key changed.
+func (it *SegmentIterator) toggle(p uint64, ub BoundType, f func(n *node) bool) bool {
+	changed := false
+	for _, i := range it.batch {
+		c := &it.cursors[i]
+		if !f(c.leaf) {
+			continue
+		}
+		if !changed {
+			it.close(p, ub)
+			changed = true
+		}
+		c.in = !c.in
+		if c.in {
+			it.enter(i)
+			continue
+		}
+		last := it.active[len(it.active)-1]
+		it.active[c.pos] = last
+		it.cursors[last].pos = c.pos
+		it.active = it.active[:len(it.active)-1]
+		c.pos = -1
+	}
+	return changed
+}
+
+func (it *SegmentIterator) enter(i int) {
+	it.cursors[i].pos = len(it.active)
+	it.active = append(it.active, i)
+}
+
+// FromSegments returns a map in which each key holds every interval of
+// the segments listing it.
+func FromSegments(segments ...Segment) *IntervalMap {
+	ivals := make(map[interface{}][]Interval)
+	for _, s := range segments {
+		for _, k := range s.Keys {
+			ivals[k] = append(ivals[k], s.Interval)
+		}
+	}
+	z := NewIntervalMap()
+	for k, v := range ivals {
+		z.Add(z, k, v...)
+	}
+	return z
+}
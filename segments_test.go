@@ -0,0 +1,78 @@
+package bandit_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/iancmcc/bandit"
+)
+
+var _ = Describe("Map segments", func() {
+
+	segments := func(m *IntervalMap) []Segment {
+		var segs []Segment
+		for it := m.Segments(); it.Next(); {
+			segs = append(segs, it.Segment())
+		}
+		return segs
+	}
+
+	expectSegments := func(m *IntervalMap, expected ...Segment) {
+		segs := segments(m)
+		Ω(segs).Should(HaveLen(len(expected)))
+		for i, s := range segs {
+			Ω(s.Interval.Equals(expected[i].Interval)).Should(BeTrue(), "%s != %s", s.Interval, expected[i].Interval)
+			Ω(s.Keys).Should(ConsistOf(expected[i].Keys...))
+		}
+	}
+
+	It("should split the domain where keys change", func() {
+		m := imap("a", Closed(0, 10))
+		m.Add(m, "b", Open(5, 20))
+		m.Add(m, "c", Point(30))
+		expectSegments(m,
+			Segment{Closed(0, 5), []interface{}{"a"}},
+			Segment{LeftOpen(5, 10), []interface{}{"a", "b"}},
+			Segment{Open(10, 20), []interface{}{"b"}},
+			Segment{Point(30), []interface{}{"c"}},
+		)
+	})
+
+	It("should split around holes and unbounded sets", func() {
+		m := imap("a", Below(5), Above(5))
+		m.Add(m, "b", AtOrAbove(3))
+		expectSegments(m,
+			Segment{Below(3), []interface{}{"a"}},
+			Segment{RightOpen(3, 5), []interface{}{"a", "b"}},
+			Segment{Point(5), []interface{}{"b"}},
+			Segment{Above(5), []interface{}{"a", "b"}},
+		)
+	})
+
+	It("should merge keys meeting at a value", func() {
+		m := imap("a", RightOpen(0, 5), Closed(5, 10))
+		expectSegments(m, Segment{Closed(0, 10), []interface{}{"a"}})
+	})
+
+	It("should narrow segments in discrete mode", func() {
+		m := imap("a", Closed(0, 4))
+		m.Add(m, "b", Open(0, 10))
+		m.SetDiscrete(true)
+		expectSegments(m,
+			Segment{Point(0), []interface{}{"a"}},
+			Segment{Closed(1, 4), []interface{}{"a", "b"}},
+			Segment{Closed(5, 9), []interface{}{"b"}},
+		)
+	})
+
+	It("should find no segments in an empty map", func() {
+		Ω(segments(NewIntervalMap())).Should(BeEmpty())
+	})
+
+	It("should rebuild a map from its segments", func() {
+		m := imap("a", Closed(0, 10), Above(40))
+		m.Add(m, "b", Open(5, 50))
+		m.Add(m, "c", Point(7))
+		Ω(FromSegments(segments(m)...).Equals(m)).Should(BeTrue())
+	})
+})
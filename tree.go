@@ -5,6 +5,8 @@ import (
 	"encoding/gob"
 	"fmt"
 	"io"
+	"math"
+	"sort"
 	"strings"
 
 	"github.com/golang/snappy"
@@ -14,21 +16,28 @@ import (
 type (
 	operation uint8
 	node      struct {
-		prefix uint64
-		level  uint
-		parent uint
-		left   uint
-		right  uint
-		count  uint
-		ul     bool
-		incl   bool
+		prefix  uint64
+		level   uint8
+		ul      bool
+		incl    bool
+		lastIn  bool
+		count   uint32
+		parent  uint
+		left    uint
+		right   uint
+		points  uint32
+		measure uint64
+		last    uint64
 	}
 	Tree struct {
 		root     uint
 		nextfree uint
 		numfree  uint
 		ul       bool
+		discrete bool
 		nodes    []node
+		// Subtree digests by node, filled in as reconciliation asks for them
+		hashes []uint64
 	}
 )
 
@@ -79,7 +88,7 @@ func (n *node) boundAbove() bool {
 func (t *Tree) node(prefix uint64, level, left, right uint, ul, incl bool) (idx uint) {
 	idx = t.cp(node{
 		prefix: prefix,
-		level:  level,
+		level:  uint8(level),
 		left:   left,
 		right:  right,
 		ul:     ul,
@@ -95,6 +104,12 @@ func (t *Tree) cp(n node) (idx uint) {
 	nn := n
 	if nn.level == 0 {
 		nn.count = 1
+		nn.points = 0
+		nn.measure = 0
+		nn.last, nn.lastIn = nn.prefix, nn.incl
+		if !nn.ul {
+			nn.points = 1
+		}
 	}
 	if t.numfree > 0 && t.nextfree == 0 {
 		panic("tree free list leak")
@@ -120,14 +135,88 @@ func (t *Tree) cp(n node) (idx uint) {
 		t.nodes = append(t.nodes, nn)
 		idx = uint(len(t.nodes) - 1)
 	}
+	if idx < uint(len(t.hashes)) {
+		// A digest cached for whatever the slot held before is stale
+		t.hashes[idx] = 0
+	}
 	if n.level != 0 {
 		l, r := &t.nodes[n.left], &t.nodes[n.right]
 		l.parent, r.parent = idx, idx
-		(&t.nodes[idx]).count = l.count + r.count
+		nn := &t.nodes[idx]
+		nn.count = l.count + r.count
+		nn.points = l.points + r.points
+		nn.measure, nn.last, nn.lastIn = joinMeasure(l, r)
 	}
 	return
 }
 
+// starts returns the number of intervals that begin at a leaf under a,
+// given membership immediately before it. Every point or hole starts one;
+// the remaining leaves alternate between opening and closing intervals.
+func (t *Tree) starts(a uint, before bool) uint {
+	n := &t.nodes[a]
+	k := uint(n.count - n.points)
+	if !before {
+		k += 1
+	}
+	return uint(n.points) + k/2
+}
+
+// recount restores the derived counts below a, which aren't serialized.
+func (t *Tree) recount(a uint) {
+	n := &t.nodes[a]
+	if n.level == 0 {
+		n.points = 0
+		if !n.ul {
+			n.points = 1
+		}
+		n.last, n.lastIn = n.prefix, n.incl
+		return
+	}
+	t.recount(n.left)
+	t.recount(n.right)
+	l, r := &t.nodes[n.left], &t.nodes[n.right]
+	n.points = l.points + r.points
+	n.measure, n.last, n.lastIn = joinMeasure(l, r)
+}
+
+// firstLeaf returns the lowest leaf under a.
+func (t *Tree) firstLeaf(a uint) *node {
+	n := &t.nodes[a]
+	for n.level != 0 {
+		n = &t.nodes[n.left]
+	}
+	return n
+}
+
+// inner returns the number of uint64 members from the first leaf under a up
+// to, but not including, the last one, given membership immediately before
+// a. A node's measure holds this count for no membership before it; the
+// other case is its complement.
+func (t *Tree) inner(a uint, before bool) uint64 {
+	n := &t.nodes[a]
+	if before {
+		return n.last - t.firstLeaf(a).prefix - n.measure
+	}
+	return n.measure
+}
+
+// joinMeasure returns the measure of a node with the given children, along
+// with the last leaf under it and whether that leaf is a member when there
+// is no membership before the node.
+func joinMeasure(l, r *node) (uint64, uint64, bool) {
+	m := l.measure
+	if l.lastIn {
+		m++
+	}
+	if !l.ul {
+		return m + r.measure, r.last, r.lastIn
+	}
+	// Past the left side, everything short of the last leaf is a member
+	// unless the right side alone would count it
+	return m + r.last - l.last - 1 - r.measure, r.last, !r.lastIn
+}
+
 func (t *Tree) capEstimate() uint {
 	return uint(len(t.nodes)) - t.numfree
 }
@@ -135,9 +224,9 @@ func (t *Tree) capEstimate() uint {
 func (t *Tree) ensureCapacity(n uint) {
 	c := uint(cap(t.nodes))
 	if n > c {
-		out := make([]node, 0, n+c)
+		out := make([]node, len(t.nodes), n+c)
 		copy(out, t.nodes)
-		t.nodes = out[:len(t.nodes)]
+		t.nodes = out
 	}
 }
 
@@ -310,7 +399,7 @@ func (t *Tree) merge(at, bt *Tree, a, b uint, aul, bul bool, op operation) (idx
 	an, bn := &at.nodes[a], &bt.nodes[b]
 	switch {
 	case an.level > bn.level:
-		if !IsPrefixAt(bn.prefix, an.prefix, an.level) {
+		if !IsPrefixAt(bn.prefix, an.prefix, uint(an.level)) {
 			// disjoint trees
 			idx = t.join(at, bt, a, b, aul, bul, op)
 			return
@@ -320,7 +409,7 @@ func (t *Tree) merge(at, bt *Tree, a, b uint, aul, bul bool, op operation) (idx
 			left, right uint
 		)
 		// Won't be needing a again
-		a_left, a_right, a_prefix, a_level := an.left, an.right, an.prefix, an.level
+		a_left, a_right, a_prefix, a_level := an.left, an.right, an.prefix, uint(an.level)
 		var tofree uint
 		if a != b || at != bt {
 			tofree = a
@@ -348,7 +437,7 @@ func (t *Tree) merge(at, bt *Tree, a, b uint, aul, bul bool, op operation) (idx
 		t.free(at, tofree, false)
 		return
 	case bn.level > an.level:
-		if !IsPrefixAt(an.prefix, bn.prefix, bn.level) {
+		if !IsPrefixAt(an.prefix, bn.prefix, uint(bn.level)) {
 			// disjoint trees
 			idx = t.join(at, bt, a, b, aul, bul, op)
 			return
@@ -357,7 +446,7 @@ func (t *Tree) merge(at, bt *Tree, a, b uint, aul, bul bool, op operation) (idx
 		var (
 			left, right uint
 		)
-		b_left, b_right, b_prefix, b_level := bn.left, bn.right, bn.prefix, bn.level
+		b_left, b_right, b_prefix, b_level := bn.left, bn.right, bn.prefix, uint(bn.level)
 		var tofree uint
 		if a != b || at != bt {
 			tofree = b
@@ -368,7 +457,7 @@ func (t *Tree) merge(at, bt *Tree, a, b uint, aul, bul bool, op operation) (idx
 			left = t.merge(at, bt, a, b_left, aul, bul, op)
 			right = t.overlap(bt, b_right, lul, op)
 		} else {
-			rul := bul != (&bt.nodes[b_right]).ul
+			rul := bul != (&bt.nodes[b_left]).ul
 			left = t.overlap(bt, b_left, aul, op)
 			right = t.merge(at, bt, a, b_right, aul, rul, op)
 		}
@@ -411,7 +500,7 @@ func (t *Tree) merge(at, bt *Tree, a, b uint, aul, bul bool, op operation) (idx
 			return
 		}
 		newul := (&t.nodes[left]).ul != (&t.nodes[right]).ul
-		idx = t.node(prefix, level, left, right, newul, false)
+		idx = t.node(prefix, uint(level), left, right, newul, false)
 		return
 	}
 }
@@ -422,6 +511,7 @@ func (t *Tree) Clear() {
 		t.nodes = append(t.nodes, node{})
 	}
 	t.nodes = t.nodes[:1]
+	t.hashes = t.hashes[:0]
 	t.ul = false
 	t.numfree = 0
 	t.nextfree = 0
@@ -440,6 +530,23 @@ func (t *Tree) mergeRoot(at, bt *Tree, a, b uint, aul, bul bool, op operation) {
 		t.ensureCapacity(an + bn)
 	}
 
+	// A discrete result can only stray from canonical form near the
+	// boundaries of whichever side isn't already canonical, so note them
+	// before the merge takes the nodes over
+	var (
+		mend         bool
+		seams, spans []uint64
+	)
+	if t.discrete {
+		switch {
+		case at.canonical(a, aul) && (!bt.canonical(b, bul) || at.nodes[a].count >= bt.nodes[b].count):
+			mend = true
+			seams, spans = bt.seams(b, bul, op == xor)
+		case bt.canonical(b, bul):
+			mend = true
+			seams, spans = at.seams(a, aul, op == xor)
+		}
+	}
 	t.root = t.merge(at, bt, a, b, aul, bul, op)
 	(&t.nodes[t.root]).parent = 0
 	switch op {
@@ -450,6 +557,233 @@ func (t *Tree) mergeRoot(at, bt *Tree, a, b uint, aul, bul bool, op operation) {
 	case xor:
 		t.ul = aul != bul
 	}
+	switch {
+	case mend:
+		t.mend(seams, spans)
+	case t.discrete:
+		t.canonicalize()
+	}
+}
+
+// toggleLeaf flips a single boundary of t.
+func (t *Tree) toggleLeaf(prefix uint64, ul, incl bool) {
+	leaf := t.node(prefix, 0, 0, 0, ul, incl)
+	t.mergeRoot(t, t, t.root, leaf, t.ul, false, xor)
+}
+
+// toggleBounds flips the boundaries of the interval with the given bounds,
+// and with them whether t holds each of its members.
+func (t *Tree) toggleBounds(lowerBound BoundType, lower, upper uint64, upperBound BoundType) {
+	switch lowerBound {
+	case UnboundBound:
+		t.ul = !t.ul
+	case OpenBound:
+		t.toggleLeaf(lower, true, false)
+	case ClosedBound:
+		t.toggleLeaf(lower, true, true)
+	}
+	switch upperBound {
+	case OpenBound:
+		t.toggleLeaf(upper, true, true)
+	case ClosedBound:
+		t.toggleLeaf(upper, true, false)
+	}
+}
+
+// canonical reports whether the subtree at a, given membership before it,
+// is the whole of a discrete t, which is therefore in canonical form.
+func (t *Tree) canonical(a uint, before bool) bool {
+	return t.discrete && a == t.root && before == t.ul
+}
+
+// seams returns the positions of the leaves under a, given membership
+// before it. Merged with a canonical tree, a can only leave the result out
+// of canonical form next to those leaves, except that xor complements the
+// canonical tree wherever a holds members. If held, it also returns the
+// ranges a holds, as pairs of bounds.
+func (t *Tree) seams(a uint, before, held bool) (seams, spans []uint64) {
+	if held && before {
+		spans = append(spans, 0)
+	}
+	if a != 0 {
+		seams, spans = t.appendSeams(make([]uint64, 0, t.nodes[a].count), spans, a, held)
+	}
+	if len(spans)%2 == 1 {
+		spans = append(spans, math.MaxUint64)
+	}
+	return
+}
+
+// appendSeams appends the positions of the leaves under a to seams, and if
+// held, those where membership changes to spans. It doesn't go through
+// eachLeaf, whose callback would have the trees of every merge escape.
+func (t *Tree) appendSeams(seams, spans []uint64, a uint, held bool) ([]uint64, []uint64) {
+	n := &t.nodes[a]
+	if n.level != 0 {
+		seams, spans = t.appendSeams(seams, spans, n.left, held)
+		return t.appendSeams(seams, spans, n.right, held)
+	}
+	seams = append(seams, n.prefix)
+	if held && n.ul {
+		spans = append(spans, n.prefix)
+	}
+	return seams, spans
+}
+
+// mend restores canonical form to a discrete t that holds it everywhere but
+// next to seams and within spans. Every boundary lies on a uint64, so t is
+// constant between neighbouring values, and strays there exactly when it
+// doesn't hold the gap just when it holds the values on both sides. Those
+// gaps are flipped by one more merge.
+func (t *Tree) mend(seams, spans []uint64) {
+	for i := 0; i < len(spans); i += 2 {
+		idx, _ := t.floorLeaf(spans[i])
+		if idx == 0 {
+			idx, _ = t.leftmostLeaf(t.root, false)
+		} else if t.nodes[idx].prefix < spans[i] {
+			idx = t.nextLeaf(idx)
+		}
+		for ; idx != 0 && t.nodes[idx].prefix <= spans[i+1]; idx = t.nextLeaf(idx) {
+			seams = append(seams, t.nodes[idx].prefix)
+		}
+	}
+	sort.Slice(seams, func(i, j int) bool { return seams[i] < seams[j] })
+	var (
+		// gaps holds v for each gap (v, v+1) to flip, with MaxUint64
+		// standing for everything above it
+		gaps    []uint64
+		checked bool
+		last    uint64
+	)
+	check := func(v uint64) {
+		if checked && v <= last {
+			return
+		}
+		checked, last = true, v
+		at, after := t.around(v)
+		if v != math.MaxUint64 {
+			next, _ := t.around(v + 1)
+			at = at && next
+		} else {
+			at = false
+		}
+		if after != at {
+			gaps = append(gaps, v)
+		}
+	}
+	for _, v := range seams {
+		if v > 0 {
+			check(v - 1)
+		}
+		check(v)
+	}
+	check(math.MaxUint64)
+	if len(gaps) == 0 && !t.ul {
+		return
+	}
+	flipped := func(v uint64) bool {
+		i := sort.Search(len(gaps), func(i int) bool { return gaps[i] >= v })
+		return i < len(gaps) && gaps[i] == v
+	}
+	// The patch holds no uint64 values, so each leaf is a member of it
+	// just before itself if and only if it is included
+	leaves := make([]node, 0, 2*len(gaps)+1)
+	leaf := func(v uint64, before bool) {
+		if n := len(leaves); n > 0 && leaves[n-1].prefix == v {
+			return
+		}
+		leaves = append(leaves, node{prefix: v, incl: before, ul: before != flipped(v)})
+	}
+	if t.ul {
+		leaf(0, true)
+	}
+	for _, v := range gaps {
+		leaf(v, v == 0 && t.ul || v > 0 && flipped(v-1))
+		if v != math.MaxUint64 {
+			leaf(v+1, true)
+		}
+	}
+	patch := &Tree{nodes: make([]node, 1, 2*len(leaves)+1), ul: t.ul}
+	patch.root, _ = patch.build(leaves, 65)
+	t.root = t.merge(t, patch, t.root, patch.root, t.ul, patch.ul, xor)
+	(&t.nodes[t.root]).parent = 0
+	t.ul = false
+}
+
+// around returns whether v is a member of t, and whether the values just
+// above it are.
+func (t *Tree) around(v uint64) (at, after bool) {
+	idx, after := t.floorLeaf(v)
+	if n := &t.nodes[idx]; idx != 0 && n.prefix == v {
+		return after != n.ul != n.incl, after
+	}
+	return after, after
+}
+
+// canonicalize rewrites t in discrete form, with every interval closed on
+// the uint64 values it holds and at least one value between neighbours. It
+// leaves t alone if it is already in that form.
+func (t *Tree) canonicalize() {
+	var (
+		canonical = true
+		next      uint64
+	)
+	t.eachInterval(func(lb BoundType, lower, upper uint64, ub BoundType) bool {
+		if lb != ClosedBound || ub != ClosedBound || (next != 0 && lower <= next) {
+			canonical = false
+			return false
+		}
+		next = upper + 1
+		return true
+	})
+	if canonical {
+		return
+	}
+	leaves := make([]node, 0, t.nodes[t.root].count+2)
+	t.eachInterval(func(lb BoundType, lower, upper uint64, ub BoundType) bool {
+		lo, hi, ok := integerRange(lb, lower, upper, ub)
+		if !ok {
+			return true
+		}
+		if n := len(leaves); n > 0 && leaves[n-1].prefix+1 == lo {
+			// Nothing lies between this interval and the last, so extend it
+			if last := &leaves[n-1]; last.ul {
+				last.prefix = hi
+				return true
+			}
+			leaves[n-1].ul = true
+		} else if lo == hi {
+			leaves = append(leaves, node{prefix: lo, incl: true})
+			return true
+		} else {
+			leaves = append(leaves, node{prefix: lo, incl: true, ul: true})
+		}
+		leaves = append(leaves, node{prefix: hi, ul: true})
+		return true
+	})
+	t.Clear()
+	if len(leaves) > 0 {
+		t.root, _ = t.build(leaves, 65)
+		(&t.nodes[t.root]).parent = 0
+	}
+}
+
+// build adds a subtree holding the leading leaves, which are sorted with no
+// repeated prefixes, that branch from the first below level. It returns the
+// subtree along with the leaves left over.
+func (t *Tree) build(leaves []node, level uint) (uint, []node) {
+	first := leaves[0]
+	idx := t.node(first.prefix, 0, 0, 0, first.ul, first.incl)
+	for leaves = leaves[1:]; len(leaves) > 0; {
+		bit := BranchingBit(first.prefix, leaves[0].prefix)
+		if bit >= level {
+			break
+		}
+		var right uint
+		right, leaves = t.build(leaves, bit)
+		idx = t.node(MaskAbove(first.prefix, bit), bit, idx, right, (&t.nodes[idx]).ul != (&t.nodes[right]).ul, false)
+	}
+	return idx, leaves
 }
 
 func (t *Tree) String() string {
@@ -554,6 +888,171 @@ func (t *Tree) nextLeaf(a uint) uint {
 	return 0
 }
 
+// eachLeaf calls f with each leaf under a in ascending order, along with
+// membership immediately before it, until f returns false.
+func (t *Tree) eachLeaf(a uint, before bool, f func(n *node, before bool) bool) bool {
+	n := &t.nodes[a]
+	if n.level == 0 {
+		return f(n, before)
+	}
+	return t.eachLeaf(n.left, before, f) &&
+		t.eachLeaf(n.right, before != (&t.nodes[n.left]).ul, f)
+}
+
+// eachInterval calls f with the bounds of each interval of t in ascending
+// order until f returns false.
+func (t *Tree) eachInterval(f func(lowerBound BoundType, lower, upper uint64, upperBound BoundType) bool) {
+	var (
+		lb    = UnboundBound
+		lower uint64
+		open  = t.ul
+	)
+	if t.root != 0 && !t.eachLeaf(t.root, t.ul, func(n *node, before bool) bool {
+		open = before != n.ul
+		if before {
+			ub := OpenBound
+			if n.ul && !n.incl {
+				ub = ClosedBound
+			}
+			plb, plower := lb, lower
+			// A hole also opens the next interval
+			lb, lower = OpenBound, n.prefix
+			return f(plb, plower, n.prefix, ub)
+		}
+		if !n.ul {
+			return f(ClosedBound, n.prefix, n.prefix, ClosedBound)
+		}
+		lb, lower = OpenBound, n.prefix
+		if n.incl {
+			lb = ClosedBound
+		}
+		return true
+	}) {
+		return
+	}
+	if open {
+		f(lb, lower, 0, UnboundBound)
+	}
+}
+
+// firstStart returns the leaf at which the first interval of t begins and
+// membership immediately before it. Leaf 0 with membership means the first
+// interval is unbounded below.
+func (t *Tree) firstStart() (uint, bool, bool) {
+	switch {
+	case t.ul:
+		return 0, true, true
+	case t.root == 0:
+		return 0, false, false
+	}
+	idx, _ := t.leftmostLeaf(t.root, false)
+	return idx, false, true
+}
+
+// intervalFrom returns the interval beginning at leaf a, given membership
+// immediately before it, and the leaf at which that interval ends, or 0 if
+// it is unbounded above.
+func (t *Tree) intervalFrom(a uint, before bool) (Interval, uint) {
+	var (
+		lb    = UnboundBound
+		lower uint64
+		end   uint
+	)
+	if a == 0 {
+		end, _ = t.leftmostLeaf(t.root, false)
+	} else {
+		n := &t.nodes[a]
+		lower, lb = n.prefix, OpenBound
+		if !before {
+			if !n.ul {
+				return Point(n.prefix), a
+			}
+			if n.incl {
+				lb = ClosedBound
+			}
+		}
+		end = t.nextLeaf(a)
+	}
+	if end == 0 {
+		return NewInterval(lb, lower, 0, UnboundBound), 0
+	}
+	e := &t.nodes[end]
+	ub := OpenBound
+	if e.ul && !e.incl {
+		ub = ClosedBound
+	}
+	return NewInterval(lb, lower, e.prefix, ub), end
+}
+
+// nextStart returns where the interval following the one that began at
+// start and ended at end begins.
+func (t *Tree) nextStart(start uint, before bool, end uint) (uint, bool, bool) {
+	if end == 0 {
+		return 0, false, false
+	}
+	if !t.nodes[end].ul && (end != start || before) {
+		// end was a hole, which also opens the next interval
+		return end, true, true
+	}
+	next := t.nextLeaf(end)
+	return next, false, next != 0
+}
+
+// nthStart returns the leaf at which the interval at index i begins, and
+// membership immediately before it.
+func (t *Tree) nthStart(i uint) (uint, bool, bool) {
+	if t.ul {
+		if i == 0 {
+			return 0, true, true
+		}
+		i -= 1
+	}
+	if t.root == 0 || i >= t.starts(t.root, t.ul) {
+		return 0, false, false
+	}
+	idx, ul := t.root, t.ul
+	for {
+		n := &t.nodes[idx]
+		if n.level == 0 {
+			return idx, ul, true
+		}
+		if ls := t.starts(n.left, ul); i >= ls {
+			i -= ls
+			ul = ul != (&t.nodes[n.left]).ul
+			idx = n.right
+		} else {
+			idx = n.left
+		}
+	}
+}
+
+// rank returns the number of intervals that begin below key.
+func (t *Tree) rank(key uint64) (r uint) {
+	if t.ul {
+		r = 1
+	}
+	if t.root == 0 {
+		return
+	}
+	idx, ul := t.root, t.ul
+	for {
+		n := &t.nodes[idx]
+		if n.level == 0 || !IsPrefixAt(key, n.prefix, uint(n.level)) {
+			if n.prefix < key {
+				r += t.starts(idx, ul)
+			}
+			return
+		}
+		if ZeroAt(key, uint(n.level)) {
+			idx = n.left
+			continue
+		}
+		r += t.starts(n.left, ul)
+		ul = ul != (&t.nodes[n.left]).ul
+		idx = n.right
+	}
+}
+
 func (t *Tree) leftEdge(key uint64) (uint, bool) {
 	var (
 		lidx uint
@@ -562,9 +1061,9 @@ func (t *Tree) leftEdge(key uint64) (uint, bool) {
 		n    node = t.nodes[idx]
 		ul   bool = t.ul
 	)
-	for n.level != 0 && IsPrefixAt(key, n.prefix, n.level) {
+	for n.level != 0 && IsPrefixAt(key, n.prefix, uint(n.level)) {
 		switch {
-		case ZeroAt(key, n.level):
+		case ZeroAt(key, uint(n.level)):
 			idx = n.left
 		default:
 			idx = n.right
@@ -592,9 +1091,9 @@ func (t *Tree) rightEdge(key uint64) (uint, bool) {
 		n    node = t.nodes[idx]
 		ul   bool = t.ul
 	)
-	for n.level != 0 && IsPrefixAt(key, n.prefix, n.level) {
+	for n.level != 0 && IsPrefixAt(key, n.prefix, uint(n.level)) {
 		switch {
-		case !ZeroAt(key, n.level):
+		case !ZeroAt(key, uint(n.level)):
 			idx = n.right
 			ul = ul != t.nodes[n.left].ul
 		default:
@@ -615,6 +1114,98 @@ func (t *Tree) rightEdge(key uint64) (uint, bool) {
 	}
 }
 
+// floorLeaf returns the last leaf of t at or below key, along with
+// membership immediately after it, or 0 and membership at -∞ if there is
+// none.
+func (t *Tree) floorLeaf(key uint64) (uint, bool) {
+	var (
+		lidx uint
+		lul  bool
+		idx  = t.root
+		ul   = t.ul
+	)
+	for idx != 0 {
+		n := &t.nodes[idx]
+		if !IsPrefixAt(key, n.prefix, uint(n.level)) {
+			if n.prefix < key {
+				// Everything under n lies below key
+				lidx, lul = idx, ul
+			}
+			break
+		}
+		if n.level == 0 {
+			lidx, lul = idx, ul
+			break
+		}
+		if ZeroAt(key, uint(n.level)) {
+			idx = n.left
+		} else {
+			lidx, lul = n.left, ul
+			ul = ul != t.nodes[n.left].ul
+			idx = n.right
+		}
+	}
+	if lidx == 0 {
+		return 0, t.ul
+	}
+	n := &t.nodes[lidx]
+	for n.level != 0 {
+		lul = lul != t.nodes[n.left].ul
+		lidx = n.right
+		n = &t.nodes[lidx]
+	}
+	return lidx, lul != n.ul
+}
+
+// eachIntervalDown calls f with the bounds of each interval of t in
+// descending order, starting with the last one that begins at or below
+// key, until f returns false.
+func (t *Tree) eachIntervalDown(key uint64, f func(lb BoundType, lower, upper uint64, ub BoundType) bool) {
+	var (
+		idx, after = t.floorLeaf(key)
+		ub         = UnboundBound
+		upper      uint64
+	)
+	if after {
+		// The first interval runs on to the next leaf, if any
+		var next uint
+		if idx == 0 {
+			next, _ = t.leftmostLeaf(t.root, false)
+		} else {
+			next = t.nextLeaf(idx)
+		}
+		if next != 0 {
+			e := &t.nodes[next]
+			upper, ub = e.prefix, OpenBound
+			if e.incl {
+				ub = ClosedBound
+			}
+		}
+	}
+	for idx != 0 {
+		n := &t.nodes[idx]
+		before := after != n.ul
+		at := before != n.incl
+		if after && !at && !f(OpenBound, n.prefix, upper, ub) {
+			return
+		}
+		if at && !after {
+			upper, ub = n.prefix, ClosedBound
+		}
+		if at && !before && !f(ClosedBound, n.prefix, upper, ub) {
+			return
+		}
+		if before && !at {
+			upper, ub = n.prefix, OpenBound
+		}
+		after = before
+		idx = t.previousLeaf(idx)
+	}
+	if after {
+		f(UnboundBound, 0, upper, ub)
+	}
+}
+
 func (n *node) GobEncode() ([]byte, error) {
 	w := new(bytes.Buffer)
 	enc := gob.NewEncoder(w)
@@ -714,6 +1305,10 @@ func (t *Tree) GobEncode() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = enc.Encode(t.discrete)
+	if err != nil {
+		return nil, err
+	}
 	return w.Bytes(), nil
 }
 
@@ -740,6 +1335,15 @@ func (t *Tree) GobDecode(buf []byte) error {
 	if err != nil {
 		return err
 	}
+	t.hashes = nil
+	// Trees encoded before discrete mode end here
+	err = enc.Decode(&t.discrete)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if t.root != 0 {
+		t.recount(t.root)
+	}
 	return nil
 }
 
@@ -2,6 +2,7 @@ package bandit_test
 
 import (
 	"bytes"
+	"encoding/base64"
 
 	. "github.com/iancmcc/bandit"
 	. "github.com/onsi/ginkgo"
@@ -37,4 +38,28 @@ var _ = Describe("Tree", func() {
 
 	})
 
+	It("should keep discrete mode through a dump and load", func() {
+		var buf bytes.Buffer
+		orig := NewIntervalSet(Closed(1, 3), Closed(4, 6)).SetDiscrete(true)
+		Ω(orig.Tree.Dump(&buf)).ShouldNot(HaveOccurred())
+		decoded, err := LoadTree(&buf)
+		Ω(err).ShouldNot(HaveOccurred())
+		newset := IntervalSet{*decoded}
+		Ω(newset.IsDiscrete()).Should(BeTrue())
+		newset.Add(&newset, Closed(7, 9))
+		Ω(newset.String()).Should(Equal("[1, 9]"))
+	})
+
+	It("should load trees dumped before discrete mode", func() {
+		// NewIntervalSet(Closed(1, 3), Open(4, 6))
+		const dumped = "/wYAAHNOYVBwWQDFAAD4sGOC0QJoCX8FAQL/ggAAAP4BRP+AAP4BPgMGAAcDBgAABQRsAgAADf+FAgEC/4YAAf+EAAAQ/4MFAQEEbm9kZQUQIAD+AQz/hgAIIAUvETcuCAAIAgAAAQQBIQABDRkAAw0IAWQIAQMCCQQBIREZEQguIQAFQgERBAIDCaIBTgEMAAIBXwEEAUIABA1bAAYNCAExEWMFIREZEQguIQAFhAFCAVchBQEMDAUDBgAyYwABMhGhAQgwBgMGAAQDAgAAAwIAAA=="
+		raw, err := base64.StdEncoding.DecodeString(dumped)
+		Ω(err).ShouldNot(HaveOccurred())
+		decoded, err := LoadTree(bytes.NewReader(raw))
+		Ω(err).ShouldNot(HaveOccurred())
+		newset := IntervalSet{*decoded}
+		Ω(newset.IsDiscrete()).Should(BeFalse())
+		Ω(newset.Equals(NewIntervalSet(Closed(1, 3), Open(4, 6)))).Should(BeTrue())
+	})
+
 })